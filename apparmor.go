@@ -0,0 +1,17 @@
+package main
+
+import "text/template"
+
+var apparmorTemplate = template.Must(
+	template.New("apparmor").Parse(`# AppArmor profile stub for {{.PkgName}} package.
+# Profile is loaded in complain mode, switch to enforce mode after
+# adding rules required by program.
+
+#include <tunables/global>
+
+/{{.BinDir}}/{{.ExecName}} flags=(complain) {
+  #include <abstractions/base>
+
+  /{{.BinDir}}/{{.ExecName}} mr,
+}
+`))
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// goArchMap maps GOARCH values to Arch Linux architecture names used in
+// arch=(). It can be extended or overridden with --arch-map.
+var goArchMap = map[string]string{
+	"386":     "i686",
+	"amd64":   "x86_64",
+	"arm":     "armv6h",
+	"arm64":   "aarch64",
+	"ppc64le": "powerpc64le",
+	"riscv64": "riscv64",
+}
+
+// parseArchMap returns copy of built-in GOARCH mapping with entries in form
+// <GOARCH>=<ARCH> added or overridden.
+func parseArchMap(specs []string) (map[string]string, error) {
+	archMap := map[string]string{}
+	for goArch, arch := range goArchMap {
+		archMap[goArch] = arch
+	}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" ||
+			strings.ContainsAny(spec, "' ") {
+			return nil, fmt.Errorf(
+				"invalid arch mapping: %q, expected <GOARCH>=<ARCH>", spec,
+			)
+		}
+
+		archMap[parts[0]] = parts[1]
+	}
+
+	return archMap, nil
+}
+
+// getGoArchList converts GOARCH values to architecture names using specified
+// mapping.
+func getGoArchList(
+	goArchs []string, archMap map[string]string,
+) ([]string, error) {
+	archs := []string{}
+	for _, goArch := range goArchs {
+		arch, ok := archMap[goArch]
+		if !ok {
+			return nil, fmt.Errorf(
+				"unknown GOARCH: %q, add mapping with --arch-map", goArch,
+			)
+		}
+
+		if !isStringInList(arch, archs) {
+			archs = append(archs, arch)
+		}
+	}
+
+	return archs, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArchMapOverridesAndExtendsMapping(t *testing.T) {
+	archMap, err := parseArchMap([]string{"arm=armv7h", "loong64=loong64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archs, err := getGoArchList(
+		[]string{"amd64", "arm", "loong64", "arm"}, archMap,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"x86_64", "armv7h", "loong64"}
+	if !reflect.DeepEqual(archs, expected) {
+		t.Fatalf("expected %q, got %q", expected, archs)
+	}
+
+	if goArchMap["arm"] != "armv6h" {
+		t.Fatalf("expected built-in mapping to be kept, got %q", goArchMap["arm"])
+	}
+}
+
+func TestGetGoArchListRejectsUnknownGoArch(t *testing.T) {
+	_, err := getGoArchList([]string{"loong64"}, goArchMap)
+	if err == nil {
+		t.Fatal("expected error for unknown GOARCH")
+	}
+}
+
+func TestParseArchMapRejectsInvalidMapping(t *testing.T) {
+	for _, spec := range []string{"arm", "=armv7h", "arm=", "arm=armv7h x"} {
+		_, err := parseArchMap([]string{spec})
+		if err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
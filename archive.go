@@ -0,0 +1,84 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// createArchive packs specified files from the output directory into gzipped
+// tarball, placing them under the prefix directory. Missing files are
+// skipped, so optional ones like .SRCINFO can be listed unconditionally.
+func createArchive(
+	target string, dir string, prefix string, names []string,
+) error {
+	logStep("Creating archive %s...", target)
+
+	output, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	defer output.Close()
+
+	compressor := gzip.NewWriter(output)
+	archive := tar.NewWriter(compressor)
+
+	for _, name := range names {
+		err = addArchiveFile(
+			archive, filepath.Join(dir, name), path.Join(prefix, name),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return err
+	}
+
+	err = compressor.Close()
+	if err != nil {
+		return err
+	}
+
+	return output.Close()
+}
+
+func addArchiveFile(archive *tar.Writer, source string, name string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+
+	err = archive.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	input, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+
+	defer input.Close()
+
+	_, err = io.Copy(archive, input)
+
+	return err
+}
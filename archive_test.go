@@ -0,0 +1,71 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateArchivePacksListedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "PKGBUILD"), "pkgname=foo\n", 0644)
+	writeTestFile(t, filepath.Join(dir, "foo.service"), "[Unit]\n", 0644)
+	writeTestFile(t, filepath.Join(dir, "src", "foo", "main.go"), "", 0644)
+
+	target := filepath.Join(t.TempDir(), "foo.tar.gz")
+
+	err := createArchive(
+		target, dir, "foo",
+		[]string{"PKGBUILD", "foo.service", ".SRCINFO"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := tar.NewReader(decompressor)
+
+	entries := map[string]string{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := ioutil.ReadAll(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries[header.Name] = string(contents)
+	}
+
+	expected := map[string]string{
+		"foo/PKGBUILD":    "pkgname=foo\n",
+		"foo/foo.service": "[Unit]\n",
+	}
+
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("expected %q, got %q", expected, entries)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	aurRPCURL     = "https://aur.archlinux.org/rpc/"
+	aurRPCTimeout = 10 * time.Second
+)
+
+type aurInfoResponse struct {
+	Type        string `json:"type"`
+	Error       string `json:"error"`
+	ResultCount int    `json:"resultcount"`
+}
+
+// isAURPackageExists queries AUR RPC at specified URL for package with
+// specified name.
+func isAURPackageExists(rpcURL string, pkgName string) (bool, error) {
+	client := http.Client{Timeout: getTimeout(aurRPCTimeout)}
+
+	response, err := client.Get(
+		rpcURL + "?v=5&type=info&arg=" + url.QueryEscape(pkgName),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("AUR RPC returned %s", response.Status)
+	}
+
+	var info aurInfoResponse
+
+	err = json.NewDecoder(response.Body).Decode(&info)
+	if err != nil {
+		return false, fmt.Errorf("can't decode AUR RPC response: %s", err)
+	}
+
+	if info.Type == "error" {
+		return false, fmt.Errorf("AUR RPC returned error: %s", info.Error)
+	}
+
+	return info.ResultCount > 0, nil
+}
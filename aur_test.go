@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsAURPackageExists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			query := request.URL.Query()
+			if query.Get("type") != "info" || query.Get("v") != "5" {
+				http.Error(writer, "bad request", http.StatusBadRequest)
+				return
+			}
+
+			count := 0
+			if query.Get("arg") == "foo" {
+				count = 1
+			}
+
+			fmt.Fprintf(
+				writer,
+				`{"version":5,"type":"multiinfo","resultcount":%d,`+
+					`"results":[]}`,
+				count,
+			)
+		},
+	))
+	defer server.Close()
+
+	tests := []struct {
+		pkgName  string
+		expected bool
+	}{
+		{"foo", true},
+		{"foo-git", false},
+	}
+
+	for _, test := range tests {
+		exists, err := isAURPackageExists(server.URL+"/rpc/", test.pkgName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if exists != test.expected {
+			t.Errorf("%s: expected exists: %t", test.pkgName, test.expected)
+		}
+	}
+}
+
+func TestIsAURPackageExistsReturnsRPCError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			fmt.Fprint(writer, `{"type":"error","error":"Incorrect request."}`)
+		},
+	))
+	defer server.Close()
+
+	_, err := isAURPackageExists(server.URL+"/rpc/", "foo")
+	if err == nil {
+		t.Fatal("expected error for RPC error response")
+	}
+}
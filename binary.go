@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// defaultPrefix is the installation prefix of binaries inside package.
+const defaultPrefix = "usr"
+
+// parsePrefix returns installation prefix relative to package root, so it
+// can be joined with '$pkgdir'.
+func parsePrefix(prefix string) (string, error) {
+	cleaned := strings.TrimLeft(path.Clean("/"+prefix), "/")
+	if cleaned == "" || strings.ContainsAny(prefix, "$\"' ") ||
+		strings.HasPrefix(path.Clean(prefix), "..") {
+		return "", fmt.Errorf("invalid prefix: %q", prefix)
+	}
+
+	return cleaned, nil
+}
+
+// getBinDir returns directory inside package, where binaries are installed
+// for specified prefix.
+func getBinDir(prefix string) string {
+	return path.Join(prefix, "bin")
+}
+
+// getBinaryInstall returns command, which installs single binary from
+// specified source path into bin directory of package under specified name.
+func getBinaryInstall(source string, binDir string, name string) string {
+	return fmt.Sprintf(
+		`install -Dm755 "%s" "$pkgdir/%s"`, source, path.Join(binDir, name),
+	)
+}
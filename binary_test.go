@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestGetBinaryInstallUsesBinaryName(t *testing.T) {
+	install := getBinaryInstall(
+		"$srcdir/go/bin/awesome-tool", getBinDir(defaultPrefix), "at",
+	)
+
+	expected := `install -Dm755 "$srcdir/go/bin/awesome-tool" "$pkgdir/usr/bin/at"`
+	if install != expected {
+		t.Fatalf("expected %q, got %q", expected, install)
+	}
+}
+
+func TestGetBinaryInstallWithPrefix(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		name     string
+		expected string
+	}{
+		{
+			defaultPrefix, "foo",
+			`install -Dm755 "$srcdir/go/bin/foo" "$pkgdir/usr/bin/foo"`,
+		},
+		{
+			"/opt/foo/", "foo",
+			`install -Dm755 "$srcdir/go/bin/foo" "$pkgdir/opt/foo/bin/foo"`,
+		},
+		{
+			"usr/local", "fo",
+			`install -Dm755 "$srcdir/go/bin/foo" "$pkgdir/usr/local/bin/fo"`,
+		},
+	}
+
+	for _, test := range tests {
+		prefix, err := parsePrefix(test.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		install := getBinaryInstall(
+			"$srcdir/go/bin/foo", getBinDir(prefix), test.name,
+		)
+		if install != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.prefix, test.expected, install)
+		}
+
+		contents := renderPkgbuild(t, pkgData{
+			PkgName:        "foo",
+			BinaryInstalls: []string{install},
+		})
+
+		assertContains(t, contents, "package() {\n\t"+install+"\n")
+	}
+}
+
+func TestParsePrefixRejectsInvalidPrefix(t *testing.T) {
+	for _, prefix := range []string{"/", "../opt", "opt/$foo", "opt/my foo"} {
+		_, err := parsePrefix(prefix)
+		if err == nil {
+			t.Errorf("%q: expected error", prefix)
+		}
+	}
+}
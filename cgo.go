@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var cgoPkgConfigRegexp = regexp.MustCompile(
+	`^\s*(?://\s*)?#cgo\s+(?:[^:]*\s)?pkg-config:(.*)$`,
+)
+
+// cgoPackages maps pkg-config names to Arch Linux packages which provide
+// them. Mapping is approximate and covers only commonly used libraries.
+var cgoPackages = map[string]string{
+	"alsa":           "alsa-lib",
+	"fuse":           "fuse2",
+	"fuse3":          "fuse3",
+	"glib-2.0":       "glib2",
+	"gobject-2.0":    "glib2",
+	"gio-2.0":        "glib2",
+	"gstreamer-1.0":  "gstreamer",
+	"gtk+-2.0":       "gtk2",
+	"gtk+-3.0":       "gtk3",
+	"gtk4":           "gtk4",
+	"libcurl":        "curl",
+	"libgit2":        "libgit2",
+	"libnotify":      "libnotify",
+	"libpcap":        "libpcap",
+	"libpulse":       "libpulse",
+	"libsystemd":     "systemd-libs",
+	"libusb-1.0":     "libusb",
+	"libzmq":         "zeromq",
+	"MagickWand":     "imagemagick",
+	"openssl":        "openssl",
+	"portaudio-2.0":  "portaudio",
+	"sqlite3":        "sqlite",
+	"vips":           "libvips",
+	"webkit2gtk-4.0": "webkit2gtk",
+	"x11":            "libx11",
+	"zlib":           "zlib",
+}
+
+func getCgoDependencies(dir string) ([]string, error) {
+	logStep("Scanning cgo directives...")
+
+	names := map[string]bool{}
+
+	err := filepath.Walk(
+		dir,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				if info.Name() == ".git" {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if filepath.Ext(path) != ".go" {
+				return nil
+			}
+
+			return scanCgoPkgConfig(path, names)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sortedNames := []string{}
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+
+	sort.Strings(sortedNames)
+
+	dependencies := []string{}
+	for _, name := range sortedNames {
+		pkg, ok := cgoPackages[name]
+		if !ok {
+			pkg = strings.ToLower(name)
+		}
+
+		logSubStep("Suggesting dependency for %s: %s", name, pkg)
+
+		if !isStringInList(pkg, dependencies) {
+			dependencies = append(dependencies, pkg)
+		}
+	}
+
+	if len(dependencies) > 0 {
+		logWarning(
+			"Dependencies derived from cgo directives are approximate, " +
+				"please review them",
+		)
+	}
+
+	return dependencies, nil
+}
+
+func scanCgoPkgConfig(path string, names map[string]bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		matches := cgoPkgConfigRegexp.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+
+		for _, name := range strings.Fields(matches[1]) {
+			if strings.HasPrefix(name, "-") {
+				continue
+			}
+
+			names[name] = true
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetCgoDependenciesSuggestsPackages(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "notify.go"), `package main
+
+// #cgo pkg-config: libnotify gtk+-3.0
+// #include <libnotify/notify.h>
+import "C"
+`, 0644)
+
+	writeTestFile(t, filepath.Join(dir, "internal", "db", "db.go"), `package db
+
+/*
+#cgo linux pkg-config: --static sqlite3
+#cgo LDFLAGS: -lm
+*/
+import "C"
+`, 0644)
+
+	writeTestFile(
+		t, filepath.Join(dir, "vendor.txt"), "#cgo pkg-config: zlib\n", 0644,
+	)
+
+	writeTestFile(
+		t, filepath.Join(dir, "custom.go"),
+		"// #cgo pkg-config: libFoo\nimport \"C\"\n", 0644,
+	)
+
+	dependencies, err := getCgoDependencies(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"gtk3", "libfoo", "libnotify", "sqlite"}
+	if !reflect.DeepEqual(dependencies, expected) {
+		t.Fatalf("expected %q, got %q", expected, dependencies)
+	}
+}
+
+func TestGetCgoDependenciesWithoutCgo(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main\n", 0644)
+
+	dependencies, err := getCgoDependencies(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dependencies) != 0 {
+		t.Fatalf("expected no dependencies, got %q", dependencies)
+	}
+}
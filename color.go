@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// isColored is set once on start and consulted by log helpers and diff
+// output, so color decision is made in single place.
+var isColored bool
+
+// shouldUseColor decides whether output should be colored. Explicit flags
+// win over environment, then non-empty NO_COLOR disables coloring as
+// described at https://no-color.org, otherwise output is colored only when
+// it goes to terminal.
+func shouldUseColor(
+	noColor bool, forceColor bool, noColorEnv string, isTTY bool,
+) (bool, error) {
+	switch {
+	case noColor && forceColor:
+		return false, errors.New(
+			"--no-color and --force-color can't be used together",
+		)
+
+	case noColor:
+		return false, nil
+
+	case forceColor:
+		return true, nil
+
+	case noColorEnv != "":
+		return false, nil
+	}
+
+	return isTTY, nil
+}
+
+// colorize wraps text in specified ANSI SGR color code, if output is
+// colored. Only foreground color is reset afterwards, so attributes like bold
+// apply to the rest of the line.
+func colorize(code string, text string) string {
+	if !isColored {
+		return text
+	}
+
+	return "\x1b[" + code + "m" + text + "\x1b[39m"
+}
+
+func setupColor(noColor bool, forceColor bool) error {
+	colored, err := shouldUseColor(
+		noColor, forceColor, os.Getenv("NO_COLOR"), isTerminal(os.Stdout),
+	)
+	if err != nil {
+		return err
+	}
+
+	isColored = colored
+
+	return nil
+}
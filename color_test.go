@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShouldUseColor(t *testing.T) {
+	tests := []struct {
+		name       string
+		noColor    bool
+		forceColor bool
+		noColorEnv string
+		isTTY      bool
+		expected   bool
+	}{
+		{"terminal", false, false, "", true, true},
+		{"non-terminal", false, false, "", false, false},
+		{"NO_COLOR", false, false, "1", true, false},
+		{"forced color", false, true, "1", false, true},
+		{"no color flag", true, false, "", true, false},
+	}
+
+	for _, test := range tests {
+		colored, err := shouldUseColor(
+			test.noColor, test.forceColor, test.noColorEnv, test.isTTY,
+		)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+
+		if colored != test.expected {
+			t.Errorf("%s: expected colored: %t", test.name, test.expected)
+		}
+	}
+
+	_, err := shouldUseColor(true, true, "", true)
+	if err == nil {
+		t.Fatal("expected error for --no-color with --force-color")
+	}
+}
+
+func TestLogStepHonorsColorDecision(t *testing.T) {
+	defer func() { isColored = false }()
+
+	t.Setenv("NO_COLOR", "1")
+
+	err := setupColor(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureOutput(t, func() { logStep("Building...") })
+	if output != "==> Building...\n" {
+		t.Fatalf("expected plain output, got %q", output)
+	}
+
+	err = setupColor(false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output = captureOutput(t, func() { logStep("Building...") })
+	if output != "\x1b[1;32m==> \x1b[39mBuilding...\n" {
+		t.Fatalf("expected colored output, got %q", output)
+	}
+}
+
+func TestIsTerminalForRegularFile(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	if isTerminal(file) {
+		t.Fatal("expected regular file not to be terminal")
+	}
+}
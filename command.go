@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// preflightTimeout limits commands run before generation, like repo checks,
+// when no timeout is set with --timeout.
+const preflightTimeout = 30 * time.Second
+
+// commandTimeout limits run time of every external command and download when
+// set with --timeout. Zero means that defaults are used.
+var commandTimeout time.Duration
+
+// execCommandContext creates external commands, so tests can replace it to
+// fake commands output.
+var execCommandContext = exec.CommandContext
+
+// getTimeout returns timeout set with --timeout or specified fallback.
+func getTimeout(fallback time.Duration) time.Duration {
+	if commandTimeout > 0 {
+		return commandTimeout
+	}
+
+	return fallback
+}
+
+// newTimeoutContext returns context which is cancelled after specified
+// timeout. Zero timeout means no limit.
+func newTimeoutContext(
+	timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// getTimeoutError replaces error of command killed because of context
+// timeout with clear one, other errors are returned as is.
+func getTimeoutError(
+	ctx context.Context, timeout time.Duration, err error,
+) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+
+	return err
+}
+
+// runOptionalHook runs hook of specified stage, if it's set.
+func runOptionalHook(
+	hooks map[string]string, stage string, pkgName string, dir string,
+) error {
+	command, ok := hooks[stage]
+	if !ok {
+		return nil
+	}
+
+	return runHook(stage, command, pkgName, dir)
+}
+
+// runHook runs user command at specified stage using shell. Hook output is
+// passed through and its failure is returned as error, so it can abort
+// generation or build. Package name and output directory are passed in
+// environment variables.
+func runHook(stage string, command string, pkgName string, dir string) error {
+	logStep("Running %s hook...", stage)
+
+	ctx, cancel := newTimeoutContext(commandTimeout)
+	defer cancel()
+
+	cmd := execCommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(
+		os.Environ(),
+		"GO_MAKEPKG_STAGE="+stage,
+		"GO_MAKEPKG_PKGNAME="+pkgName,
+		"GO_MAKEPKG_DIR="+dir,
+	)
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf(
+			"%s hook failed: %s", stage, getTimeoutError(ctx, commandTimeout, err),
+		)
+	}
+
+	return nil
+}
+
+// getCommandPkgVer runs specified shell command and converts its output to
+// package version: leading 'v' is removed, while dashes and whitespace, which
+// are not allowed in pkgver, are replaced with dots.
+func getCommandPkgVer(command string) (string, error) {
+	timeout := getTimeout(preflightTimeout)
+
+	ctx, cancel := newTimeoutContext(timeout)
+	defer cancel()
+
+	cmd := execCommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf(
+			"pkgver command failed: %s", getTimeoutError(ctx, timeout, err),
+		)
+	}
+
+	version := strings.TrimPrefix(strings.TrimSpace(string(output)), "v")
+	version = strings.Join(
+		strings.FieldsFunc(version, func(char rune) bool {
+			return char == '-' || unicode.IsSpace(char)
+		}),
+		".",
+	)
+
+	if !isValidPkgVer(version) {
+		return "", fmt.Errorf(
+			"pkgver command printed invalid version: %q",
+			strings.TrimSpace(string(output)),
+		)
+	}
+
+	return version, nil
+}
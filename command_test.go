@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCommands replaces command runner until the end of the test: every
+// command is recorded and replaced with specified shell script, which gets
+// command name as $0 and its arguments as positional parameters.
+func fakeCommands(t *testing.T, script string) *[][]string {
+	commands := [][]string{}
+
+	execCommandContext = func(
+		ctx context.Context, name string, args ...string,
+	) *exec.Cmd {
+		commands = append(commands, append([]string{name}, args...))
+
+		return exec.CommandContext(
+			ctx, "sh", append([]string{"-c", script, name}, args...)...,
+		)
+	}
+
+	t.Cleanup(func() {
+		execCommandContext = exec.CommandContext
+	})
+
+	return &commands
+}
+
+// setCommandTimeout sets --timeout value until the end of the test.
+func setCommandTimeout(t *testing.T, timeout time.Duration) {
+	commandTimeout = timeout
+
+	t.Cleanup(func() {
+		commandTimeout = 0
+	})
+}
+
+func TestCommandsExceedingTimeoutAreKilled(t *testing.T) {
+	setCommandTimeout(t, 100*time.Millisecond)
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"preflight check", func() error {
+			return checkRepo("https://example.com/repo")
+		}},
+		{"build", func() error {
+			return runBuild(t.TempDir(), buildOptions{})
+		}},
+	}
+
+	for _, test := range tests {
+		fakeCommands(t, "exec sleep 10")
+
+		started := time.Now()
+
+		err := test.run()
+		if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+			t.Errorf("%s: expected timeout error, got %v", test.name, err)
+		}
+
+		if time.Since(started) > 5*time.Second {
+			t.Errorf("%s: command was not killed on timeout", test.name)
+		}
+	}
+}
+
+func TestGetTimeoutFallsBackToDefault(t *testing.T) {
+	if timeout := getTimeout(preflightTimeout); timeout != preflightTimeout {
+		t.Fatalf("expected %s, got %s", preflightTimeout, timeout)
+	}
+
+	setCommandTimeout(t, time.Minute)
+
+	if timeout := getTimeout(preflightTimeout); timeout != time.Minute {
+		t.Fatalf("expected %s, got %s", time.Minute, timeout)
+	}
+}
+
+func TestRunBuildWithHooksRunsHooksAroundBuild(t *testing.T) {
+	commands := fakeCommands(t, "exit 0")
+
+	err := runBuildWithHooks(t.TempDir(), buildOptions{}, map[string]string{
+		"pre-gen":    "echo pre-gen",
+		"pre-build":  "echo pre-build",
+		"post-build": "echo post-build",
+	}, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"sh", "-c", "echo pre-build"},
+		{"makepkg", "-f"},
+		{"sh", "-c", "echo post-build"},
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, *commands)
+	}
+}
+
+func TestRunBuildWithHooksStopsOnFailingHook(t *testing.T) {
+	commands := fakeCommands(t, "exit 1")
+
+	err := runBuildWithHooks(t.TempDir(), buildOptions{}, map[string]string{
+		"pre-build":  "false",
+		"post-build": "echo post-build",
+	}, "foo")
+	if err == nil || !strings.Contains(err.Error(), "pre-build hook failed") {
+		t.Fatalf("expected pre-build hook failure, got %v", err)
+	}
+
+	expected := [][]string{{"sh", "-c", "false"}}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected build to be aborted, got %q", *commands)
+	}
+}
+
+func TestRunOptionalHookPassesStageEnvironment(t *testing.T) {
+	fakeCommands(
+		t, `echo "$GO_MAKEPKG_STAGE $GO_MAKEPKG_PKGNAME $GO_MAKEPKG_DIR"`,
+	)
+
+	hooks := map[string]string{"post-gen": "true"}
+
+	output := captureOutput(t, func() {
+		for _, stage := range []string{"pre-gen", "post-gen"} {
+			err := runOptionalHook(hooks, stage, "foo", "build")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	if !strings.HasSuffix(output, "post-gen foo build\n") ||
+		strings.Contains(output, "pre-gen") {
+		t.Fatalf("expected only post-gen hook to run, got %q", output)
+	}
+}
+
+func TestGetCommandPkgVer(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{"v1.2.3", "1.2.3"},
+		{"1.2.3-4-gabcdef", "1.2.3.4.gabcdef"},
+		{"  2021 03 01  ", "2021.03.01"},
+	}
+
+	for _, test := range tests {
+		commands := fakeCommands(t, "echo '"+test.output+"'")
+
+		version, err := getCommandPkgVer("git describe --tags")
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.output, err)
+			continue
+		}
+
+		if version != test.expected {
+			t.Errorf(
+				"%q: expected %q, got %q", test.output, test.expected, version,
+			)
+		}
+
+		expected := [][]string{{"sh", "-c", "git describe --tags"}}
+		if !reflect.DeepEqual(*commands, expected) {
+			t.Errorf("expected %q, got %q", expected, *commands)
+		}
+	}
+}
+
+func TestGetCommandPkgVerRejectsInvalidVersion(t *testing.T) {
+	scripts := []string{
+		"echo 'version: 1.0'",
+		"echo",
+		"echo 1.0; exit 1",
+	}
+
+	for _, script := range scripts {
+		fakeCommands(t, script)
+
+		_, err := getCommandPkgVer("cat VERSION")
+		if err == nil {
+			t.Errorf("%q: expected error", script)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// completionDirs maps shells to directories, where their completion scripts
+// are loaded from.
+var completionDirs = map[string]string{
+	"bash": "usr/share/bash-completion/completions",
+	"fish": "usr/share/fish/vendor_completions.d",
+	"zsh":  zshCompletionDir,
+}
+
+// getCompletionPath returns path, where completion script for specified
+// binary should be installed for specified shell.
+func getCompletionPath(shell string, execName string) (string, error) {
+	dir, ok := completionDirs[shell]
+	if !ok {
+		return "", fmt.Errorf(
+			"unsupported completion shell: %q, expected bash, fish or zsh",
+			shell,
+		)
+	}
+
+	switch shell {
+	case "zsh":
+		return path.Join(dir, "_"+execName), nil
+	case "fish":
+		return path.Join(dir, execName+".fish"), nil
+	default:
+		return path.Join(dir, execName), nil
+	}
+}
+
+// getBinaryCompletion returns completion script printed by cobra-style
+// 'completion <shell>' subcommand of specified binary.
+func getBinaryCompletion(binary string, shell string) (string, error) {
+	timeout := getTimeout(preflightTimeout)
+
+	ctx, cancel := newTimeoutContext(timeout)
+	defer cancel()
+
+	stderr := &bytes.Buffer{}
+
+	cmd := execCommandContext(ctx, binary, "completion", shell)
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf(
+			"%s\n%s",
+			getTimeoutError(ctx, timeout, err),
+			strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	if strings.TrimSpace(string(output)) == "" {
+		return "", fmt.Errorf("no completion script printed")
+	}
+
+	return string(output), nil
+}
+
+// prepareCompletionFile writes completion script of specified binary for
+// specified shell into output directory and returns it as file installed
+// into completion directory of the shell.
+func prepareCompletionFile(
+	completion string, shell string, execName string, dirName string,
+	dryRun bool,
+) (pkgFile, error) {
+	completionPath, err := getCompletionPath(shell, execName)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	completionName := execName + "." + shell + "-completion"
+
+	hash, err := writeOutputFile(
+		filepath.Join(dirName, completionName), []byte(completion), dryRun,
+	)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return pkgFile{
+		Name: completionName,
+		Path: completionPath,
+		Hash: hash,
+		Mode: "0644",
+	}, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetBinaryCompletionRunsCompletionSubcommand(t *testing.T) {
+	commands := fakeCommands(t, "echo '#compdef foo'; echo '_foo() {}'")
+
+	completion, err := getBinaryCompletion("/tmp/pkg/foo/usr/bin/foo", "zsh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if completion != "#compdef foo\n_foo() {}\n" {
+		t.Fatalf("unexpected completion: %q", completion)
+	}
+
+	expected := [][]string{{"/tmp/pkg/foo/usr/bin/foo", "completion", "zsh"}}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, *commands)
+	}
+}
+
+func TestGetBinaryCompletionFailsWithoutSubcommand(t *testing.T) {
+	tests := []string{
+		"echo 'unknown command \"completion\"' >&2; exit 1",
+		"exit 0",
+	}
+
+	for _, script := range tests {
+		fakeCommands(t, script)
+
+		_, err := getBinaryCompletion("foo", "zsh")
+		if err == nil {
+			t.Errorf("%q: expected error", script)
+		}
+	}
+}
+
+func TestPrepareCompletionFileInstallsIntoShellDir(t *testing.T) {
+	tests := []struct {
+		shell    string
+		expected string
+	}{
+		{"zsh", "usr/share/zsh/site-functions/_foo"},
+		{"bash", "usr/share/bash-completion/completions/foo"},
+		{"fish", "usr/share/fish/vendor_completions.d/foo.fish"},
+	}
+
+	for _, test := range tests {
+		dirName := t.TempDir()
+
+		file, err := prepareCompletionFile(
+			"complete foo\n", test.shell, "foo", dirName, false,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if file.Path != test.expected {
+			t.Errorf(
+				"%s: expected path %q, got %q",
+				test.shell, test.expected, file.Path,
+			)
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(dirName, file.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(contents) != "complete foo\n" {
+			t.Errorf("%s: unexpected contents: %q", test.shell, contents)
+		}
+	}
+}
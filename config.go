@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+const projectConfigName = ".go-makepkg.toml"
+
+type config struct {
+	Maintainer       string   `toml:"maintainer"`
+	License          string   `toml:"license"`
+	Dependencies     []string `toml:"depends"`
+	MakeDependencies []string `toml:"makedepends"`
+}
+
+// loadDefaults resolves option defaults in the following order, each next
+// source overriding previous one: built-in defaults, user configuration file,
+// project configuration file. Command line flags override all of them.
+func loadDefaults() (config, error) {
+	defaults := config{
+		License: "GPL",
+	}
+
+	defaults.Maintainer, _ = getMaintainerInfo()
+
+	for _, path := range []string{getUserConfigPath(), projectConfigName} {
+		err := loadConfig(path, &defaults)
+		if err != nil {
+			return defaults, err
+		}
+	}
+
+	return defaults, nil
+}
+
+func loadConfig(path string, target *config) error {
+	_, err := toml.DecodeFile(path, target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func getUserConfigPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+
+	return filepath.Join(configHome, "go-makepkg", "config.toml")
+}
+
+// printConfig writes resolved options, including defaults taken from
+// configuration files, as JSON object keyed by option names.
+func printConfig(output io.Writer, args map[string]interface{}) error {
+	options := map[string]interface{}{}
+	for name, value := range args {
+		switch name {
+		case "--help", "--version", "--print-config":
+			continue
+		}
+
+		options[name] = value
+	}
+
+	contents, err := json.MarshalIndent(options, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = output.Write(append(contents, '\n'))
+
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docopt/docopt-go"
+)
+
+// setupConfigTest isolates configuration lookup from the real user: home,
+// configuration directory and working directory point to empty temporary
+// directories, which are returned.
+func setupConfigTest(t *testing.T) (string, string) {
+	home, project := t.TempDir(), t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	chdirTest(t, project)
+
+	return home, project
+}
+
+func TestLoadDefaultsUsesBuiltInDefaults(t *testing.T) {
+	setupConfigTest(t)
+
+	defaults, err := loadDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if defaults.License != "GPL" {
+		t.Fatalf("expected built-in license GPL, got %q", defaults.License)
+	}
+}
+
+func TestLoadDefaultsProjectConfigOverridesUserConfig(t *testing.T) {
+	home, project := setupConfigTest(t)
+
+	writeTestFile(
+		t, filepath.Join(home, ".config", "go-makepkg", "config.toml"),
+		"maintainer = \"user <user@example.com>\"\n"+
+			"license = \"MIT\"\n"+
+			"depends = [\"glibc\"]\n",
+		0644,
+	)
+
+	writeTestFile(
+		t, filepath.Join(project, projectConfigName),
+		"license = \"BSD\"\n",
+		0644,
+	)
+
+	defaults, err := loadDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := config{
+		Maintainer:   "user <user@example.com>",
+		License:      "BSD",
+		Dependencies: []string{"glibc"},
+	}
+
+	if !reflect.DeepEqual(defaults, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, defaults)
+	}
+}
+
+func TestReplaceUsageDefaultsFlagsOverrideConfig(t *testing.T) {
+	doc := replaceUsageDefaults(usage, config{License: "BSD"})
+
+	tests := []struct {
+		argv    []string
+		license string
+	}{
+		{[]string{"desc", "repo"}, "BSD"},
+		{[]string{"-l", "MIT", "desc", "repo"}, "MIT"},
+	}
+
+	for _, test := range tests {
+		args, err := docopt.Parse(
+			doc, test.argv, false, "", false, false,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if args[`-l`] != test.license {
+			t.Fatalf(
+				"%q: expected license %q, got %v",
+				test.argv, test.license, args[`-l`],
+			)
+		}
+	}
+}
+
+func TestPrintConfigReflectsFlagOverrides(t *testing.T) {
+	doc := replaceUsageDefaults(usage, config{
+		Maintainer: "user <user@example.com>",
+		License:    "BSD",
+	})
+
+	args, err := docopt.Parse(
+		doc, []string{"--print-config", "-l", "MIT", "desc", "repo"},
+		false, "", false, false,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := &bytes.Buffer{}
+	err = printConfig(output, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := map[string]interface{}{}
+	err = json.Unmarshal(output.Bytes(), &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options[`-l`] != "MIT" || options[`-m`] != "user <user@example.com>" {
+		t.Fatalf("unexpected options: %s", output)
+	}
+
+	if _, ok := options[`--print-config`]; ok {
+		t.Fatalf("expected no --print-config in options: %s", output)
+	}
+}
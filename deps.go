@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lintRelations returns warnings about contradictory package relations, like
+// depending on package which is also listed in conflicts. Version constraints
+// are ignored when comparing names. Package both providing and conflicting
+// with same name is fine, that's how variant packages replace base one.
+func lintRelations(depends, provides, conflicts []string) []string {
+	warnings := []string{}
+
+	pairs := []struct {
+		kind  string
+		first []string
+		other string
+		list  []string
+	}{
+		{"depends", depends, "provides", provides},
+		{"depends", depends, "conflicts", conflicts},
+	}
+
+	for _, pair := range pairs {
+		for _, first := range pair.first {
+			for _, second := range pair.list {
+				if getRelationName(first) != getRelationName(second) {
+					continue
+				}
+
+				warnings = append(warnings, fmt.Sprintf(
+					"%q is listed in both %s and %s",
+					getRelationName(first), pair.kind, pair.other,
+				))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// addVariantRelations adds base package name to provides and conflicts of
+// variant package like '-git' or '-bin', unless it's disabled.
+func addVariantRelations(
+	baseName string, pkgName string, provides []string, conflicts []string,
+	isDisabled bool,
+) ([]string, []string) {
+	if baseName == pkgName || isDisabled {
+		return provides, conflicts
+	}
+
+	if !isRelationInList(baseName, provides) {
+		provides = append(provides, baseName)
+	}
+
+	if !isRelationInList(baseName, conflicts) {
+		conflicts = append(conflicts, baseName)
+	}
+
+	return provides, conflicts
+}
+
+// isRelationInList checks if list contains relation for package with
+// specified name.
+func isRelationInList(name string, list []string) bool {
+	for _, relation := range list {
+		if getRelationName(relation) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getRelationName returns package name from relation like 'foo>=1.0' or
+// 'foo: optional description'.
+func getRelationName(relation string) string {
+	if index := strings.IndexAny(relation, "<>=:"); index >= 0 {
+		relation = relation[:index]
+	}
+
+	return strings.TrimSpace(relation)
+}
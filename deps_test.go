@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintRelationsDetectsDependsConflictsOverlap(t *testing.T) {
+	warnings := lintRelations(
+		[]string{"glibc", "openssl>=3.0"},
+		[]string{"foo"},
+		[]string{"openssl<3.0", "foo-git"},
+	)
+
+	expected := []string{
+		`"openssl" is listed in both depends and conflicts`,
+	}
+
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("expected %q, got %q", expected, warnings)
+	}
+}
+
+func TestLintRelationsAcceptsCleanRelations(t *testing.T) {
+	warnings := lintRelations(
+		[]string{"glibc", "openssl"},
+		[]string{"foo"},
+		[]string{"foo-git"},
+	)
+
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %q", warnings)
+	}
+}
+
+func TestLintRelationsAcceptsProvidesConflictsOverlap(t *testing.T) {
+	warnings := lintRelations(
+		[]string{"glibc"},
+		[]string{"foo=1.0"},
+		[]string{"foo"},
+	)
+
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %q", warnings)
+	}
+}
+
+func TestGetRelationName(t *testing.T) {
+	tests := []struct {
+		relation string
+		expected string
+	}{
+		{"foo", "foo"},
+		{"foo>=1.0", "foo"},
+		{"foo=1.0-1", "foo"},
+		{"foo: optional support", "foo"},
+	}
+
+	for _, test := range tests {
+		name := getRelationName(test.relation)
+		if name != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.relation, test.expected, name)
+		}
+	}
+}
+
+func TestAddVariantRelations(t *testing.T) {
+	tests := []struct {
+		pkgName           string
+		provides          []string
+		isDisabled        bool
+		expectedProvides  []string
+		expectedConflicts []string
+	}{
+		{"foo-git", []string{}, false, []string{"foo"}, []string{"foo"}},
+		{
+			"foo-bin", []string{"foo=1.0"}, false,
+			[]string{"foo=1.0"}, []string{"foo"},
+		},
+		{"foo-git", []string{}, true, []string{}, []string{}},
+		{"foo", []string{}, false, []string{}, []string{}},
+	}
+
+	for _, test := range tests {
+		provides, conflicts := addVariantRelations(
+			"foo", test.pkgName, test.provides, []string{}, test.isDisabled,
+		)
+
+		if !reflect.DeepEqual(provides, test.expectedProvides) {
+			t.Errorf(
+				"%s: expected provides %q, got %q",
+				test.pkgName, test.expectedProvides, provides,
+			)
+		}
+
+		if !reflect.DeepEqual(conflicts, test.expectedConflicts) {
+			t.Errorf(
+				"%s: expected conflicts %q, got %q",
+				test.pkgName, test.expectedConflicts, conflicts,
+			)
+		}
+	}
+}
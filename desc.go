@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// gitDescriptionSuffix is conventionally appended to description of packages
+// built from latest git commit.
+const gitDescriptionSuffix = "(git version)"
+
+// getDescriptionSuffix returns suffix set with --desc-suffix or, if it's not
+// set, '(git version)' for packages versioned by git commits.
+func getDescriptionSuffix(rawSuffix interface{}, isGitVersion bool) string {
+	if rawSuffix != nil {
+		return strings.TrimSpace(rawSuffix.(string))
+	}
+
+	if isGitVersion {
+		return gitDescriptionSuffix
+	}
+
+	return ""
+}
+
+// addDescriptionSuffix appends suffix to description separated by space.
+func addDescriptionSuffix(desc string, suffix string) string {
+	if suffix == "" {
+		return desc
+	}
+
+	if desc == "" {
+		return suffix
+	}
+
+	return desc + " " + suffix
+}
+
+func lintDescription(desc string, pkgName string, maxLength int) []string {
+	warnings := []string{}
+
+	if length := utf8.RuneCountInString(desc); length > maxLength {
+		warnings = append(warnings, fmt.Sprintf(
+			"description is %d characters long, which exceeds %d",
+			length, maxLength,
+		))
+	}
+
+	if getDescriptionNamePrefix(desc, pkgName) != "" {
+		warnings = append(warnings, fmt.Sprintf(
+			"description should not start with package name %q", pkgName,
+		))
+	}
+
+	if strings.HasSuffix(desc, ".") {
+		warnings = append(warnings, "description should not end with period")
+	}
+
+	return warnings
+}
+
+func fixDescription(desc string, pkgName string, maxLength int) string {
+	desc = strings.TrimPrefix(desc, getDescriptionNamePrefix(desc, pkgName))
+	desc = strings.TrimRight(desc, ".")
+
+	if utf8.RuneCountInString(desc) > maxLength {
+		desc = string([]rune(desc)[:maxLength])
+		if index := strings.LastIndex(desc, " "); index > 0 {
+			desc = desc[:index]
+		}
+
+		desc = strings.TrimRight(desc, " ,.;:-")
+	}
+
+	if desc != "" {
+		first, size := utf8.DecodeRuneInString(desc)
+		desc = strings.ToUpper(string(first)) + desc[size:]
+	}
+
+	return desc
+}
+
+// getDescriptionNamePrefix returns leading package name in the description
+// along with following separator, like 'tool - ' or 'tool: ', or empty string
+// if description does not start with package name.
+func getDescriptionNamePrefix(desc string, pkgName string) string {
+	prefix := regexp.MustCompile(
+		`(?i)^` + regexp.QuoteMeta(pkgName) + `(\s*[-:,]\s*|\s+|$)`,
+	)
+
+	return prefix.FindString(desc)
+}
+
+// escapeDescription escapes characters which are special inside of double
+// quotes in shell, so description read from project files is rendered to
+// PKGBUILD literally.
+func escapeDescription(desc string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`",
+	).Replace(desc)
+}
+
+var (
+	changelogMarkupRegexp  = regexp.MustCompile(`^(?:[#>*+-]+\s*)+`)
+	changelogVersionRegexp = regexp.MustCompile(
+		`^\[?v?\d+(?:\.\d+)*[^\s\]]*\]?\s*` + // version
+			`(?:\(.*?\)\s*)?(?:-\s*\d{4}-\d{2}-\d{2})?` + // date
+			`[:\s-]*`,
+	)
+	changelogTitleRegexp = regexp.MustCompile(
+		`(?i)^(change\s*log|changes|history|release notes|news)$`,
+	)
+	changelogRuleRegexp = regexp.MustCompile(`^[=*_-]{3,}$`)
+)
+
+// getChangelogDescription returns first meaningful line of changelog, which
+// is not title, version header or rule, stripped of markdown markup.
+func getChangelogDescription(name string) (string, error) {
+	contents, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if changelogRuleRegexp.MatchString(line) ||
+			strings.HasPrefix(line, "<!--") {
+			continue
+		}
+
+		line = changelogMarkupRegexp.ReplaceAllString(line, "")
+		line = changelogVersionRegexp.ReplaceAllString(line, "")
+		line = strings.TrimSpace(strings.Trim(line, "*_`"))
+
+		if line == "" || changelogTitleRegexp.MatchString(line) {
+			continue
+		}
+
+		return strings.TrimRight(line, "."), nil
+	}
+
+	return "", fmt.Errorf("no description found in changelog %q", name)
+}
+
+// getGodocDescription returns first sentence of the main package doc comment
+// found in the specified directory, without trailing period. Empty string is
+// returned if main package has no doc comment.
+func getGodocDescription(dir string) (string, error) {
+	packages, err := parser.ParseDir(
+		token.NewFileSet(), dir,
+		func(info os.FileInfo) bool {
+			return !strings.HasSuffix(info.Name(), "_test.go")
+		},
+		parser.ParseComments|parser.PackageClauseOnly,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	pkg, ok := packages["main"]
+	if !ok {
+		return "", fmt.Errorf("no main package found in %q", dir)
+	}
+
+	synopsis := doc.Synopsis(doc.New(pkg, dir, doc.AllDecls).Doc)
+
+	return strings.TrimRight(synopsis, "."), nil
+}
+
+// getDefaultDescription returns generic description for program, which is
+// used when no description can be read from its sources.
+func getDefaultDescription(programName string) string {
+	return "Go program " + programName
+}
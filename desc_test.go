@@ -0,0 +1,254 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintDescription(t *testing.T) {
+	tests := []struct {
+		desc     string
+		expected string
+	}{
+		{"Fast file finder", ""},
+		{strings.Repeat("long ", 20), "exceeds 80"},
+		{"foo - fast file finder", "start with package name"},
+		{"Fast file finder.", "end with period"},
+	}
+
+	for _, test := range tests {
+		warnings := lintDescription(test.desc, "foo", 80)
+
+		switch {
+		case test.expected == "" && len(warnings) != 0:
+			t.Errorf("%q: expected no warnings, got %q", test.desc, warnings)
+
+		case test.expected != "" && (len(warnings) != 1 ||
+			!strings.Contains(warnings[0], test.expected)):
+			t.Errorf(
+				"%q: expected warning about %q, got %q",
+				test.desc, test.expected, warnings,
+			)
+		}
+	}
+}
+
+func TestFixDescription(t *testing.T) {
+	tests := []struct {
+		desc     string
+		expected string
+	}{
+		{"Fast file finder", "Fast file finder"},
+		{"foo - fast file finder.", "Fast file finder"},
+		{"FOO: fast file finder", "Fast file finder"},
+		{"foobar tool", "Foobar tool"},
+		{"Fast file finder, written in Go", "Fast file finder"},
+	}
+
+	for _, test := range tests {
+		desc := fixDescription(test.desc, "foo", 20)
+		if desc != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.desc, test.expected, desc)
+		}
+
+		if warnings := lintDescription(desc, "foo", 20); len(warnings) != 0 {
+			t.Errorf("%q: expected fixed description, got %q", desc, warnings)
+		}
+	}
+}
+
+func TestGetDescriptionSuffix(t *testing.T) {
+	tests := []struct {
+		rawSuffix    interface{}
+		isGitVersion bool
+		expected     string
+	}{
+		{nil, true, "(git version)"},
+		{nil, false, ""},
+		{" (nightly) ", true, "(nightly)"},
+		{"(nightly)", false, "(nightly)"},
+		{"", true, ""},
+	}
+
+	for _, test := range tests {
+		suffix := getDescriptionSuffix(test.rawSuffix, test.isGitVersion)
+		if suffix != test.expected {
+			t.Errorf(
+				"%v, git: %t: expected %q, got %q",
+				test.rawSuffix, test.isGitVersion, test.expected, suffix,
+			)
+		}
+	}
+}
+
+func TestDescriptionSuffixCountsTowardsMaxLength(t *testing.T) {
+	desc := addDescriptionSuffix("Fast file finder", gitDescriptionSuffix)
+	if desc != "Fast file finder (git version)" {
+		t.Fatalf("unexpected description: %q", desc)
+	}
+
+	warnings := lintDescription(desc, "foo", len(desc))
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %q", warnings)
+	}
+
+	warnings = lintDescription(desc, "foo", len(desc)-1)
+	if len(warnings) != 1 {
+		t.Fatalf("expected length warning, got %q", warnings)
+	}
+}
+
+func TestGetGodocDescription(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "doc.go"), `// Command foo finds files
+// fast. It walks directories in parallel.
+package main
+`, 0644)
+
+	writeTestFile(t, filepath.Join(dir, "main.go"), `package main
+
+func main() {}
+`, 0644)
+
+	desc, err := getGodocDescription(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if desc != "Command foo finds files fast" {
+		t.Fatalf("unexpected description: %q", desc)
+	}
+}
+
+func TestGetGodocDescriptionWithoutDocComment(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "main.go"), `package main
+
+// main is not package doc comment.
+func main() {}
+`, 0644)
+
+	desc, err := getGodocDescription(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if desc != "" {
+		t.Fatalf("expected empty description, got %q", desc)
+	}
+}
+
+func TestGodocDescriptionIsEscaped(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "main.go"), `// Command foo runs `+
+		"`cmd`"+` in "$HOME".
+package main
+
+func main() {}
+`, 0644)
+
+	desc, err := getGodocDescription(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkgdesc := evalPkgdesc(t, escapeDescription(desc)); pkgdesc != desc {
+		t.Fatalf("expected pkgdesc %q, got %q", desc, pkgdesc)
+	}
+}
+
+func TestGetChangelogDescription(t *testing.T) {
+	tests := []struct {
+		changelog string
+		expected  string
+	}{
+		{
+			"# Changelog\n\n" +
+				"<!-- generated by release tool -->\n" +
+				"Fast file finder with regexp support.\n\n" +
+				"## [1.2.0] - 2021-03-01\n- Added globs\n",
+			"Fast file finder with regexp support",
+		},
+		{
+			"CHANGES\n=======\n\n" +
+				"v1.2.0 (2021-03-01): **Fast file finder**\n",
+			"Fast file finder",
+		},
+		{
+			"## 1.2.0\n\n* Fast file finder\n",
+			"Fast file finder",
+		},
+	}
+
+	for _, test := range tests {
+		name := filepath.Join(t.TempDir(), "CHANGELOG.md")
+		writeTestFile(t, name, test.changelog, 0644)
+
+		desc, err := getChangelogDescription(name)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.changelog, err)
+			continue
+		}
+
+		if desc != test.expected {
+			t.Errorf(
+				"%q: expected %q, got %q", test.changelog, test.expected, desc,
+			)
+		}
+	}
+}
+
+func TestGetChangelogDescriptionWithoutDescription(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "CHANGELOG.md")
+	writeTestFile(t, name, "# Changelog\n\n## [1.0.0]\n---\n", 0644)
+
+	_, err := getChangelogDescription(name)
+	if err == nil {
+		t.Fatal("expected error for changelog without description")
+	}
+}
+
+func TestChangelogDescriptionIsEscaped(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "CHANGELOG.md")
+	writeTestFile(
+		t, name, "# Changelog\n\nRun `cmd` to find \"$HOME\\files\"\n", 0644,
+	)
+
+	desc, err := getChangelogDescription(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkgdesc := evalPkgdesc(t, escapeDescription(desc)); pkgdesc != desc {
+		t.Fatalf("expected pkgdesc %q, got %q", desc, pkgdesc)
+	}
+}
+
+// evalPkgdesc renders PKGBUILD with specified description and returns value
+// of pkgdesc as it's seen by shell.
+func evalPkgdesc(t *testing.T, desc string) string {
+	t.Helper()
+
+	pkgdesc := ""
+	for _, line := range strings.Split(
+		renderPkgbuild(t, pkgData{PkgDesc: desc}), "\n",
+	) {
+		if strings.HasPrefix(line, "pkgdesc=") {
+			pkgdesc = line
+		}
+	}
+
+	output, err := exec.Command(
+		"sh", "-c", pkgdesc+`; printf %s "$pkgdesc"`,
+	).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(output)
+}
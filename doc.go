@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// docGzipMinSize is minimal size of documentation file to be compressed
+// with --gzip-doc, smaller files are not worth it.
+const docGzipMinSize = 4 * 1024
+
+func getDocDir(pkgName string) string {
+	return path.Join("usr/share/doc", pkgName)
+}
+
+func prepareDocFile(name string, pkgName string) (pkgFile, error) {
+	hash, err := getFileHash(name)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return pkgFile{
+		Source: name,
+		Path:   path.Join(getDocDir(pkgName), filepath.Base(name)),
+		Name:   filepath.Base(name),
+		Hash:   hash,
+		Mode:   "0644",
+	}, nil
+}
+
+// isDocCompressible checks if documentation file is large enough to be
+// compressed.
+func isDocCompressible(name string) (bool, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return false, err
+	}
+
+	return info.Size() >= docGzipMinSize, nil
+}
+
+// createGzipDoc compresses documentation file into output directory. Gzip
+// header contains neither name nor modification time, so result depends
+// only on file contents. Nothing is written in dry run.
+func createGzipDoc(
+	name string, outDir string, pkgName string, dryRun bool,
+) (pkgFile, error) {
+	input, err := os.Open(name)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	defer input.Close()
+
+	gzipName := filepath.Base(name) + ".gz"
+
+	output := &bytes.Buffer{}
+
+	compressor, err := gzip.NewWriterLevel(output, gzip.BestCompression)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	_, err = io.Copy(compressor, input)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	err = compressor.Close()
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	hash, err := writeOutputFile(
+		filepath.Join(outDir, gzipName), output.Bytes(), dryRun,
+	)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return pkgFile{
+		Path: path.Join(getDocDir(pkgName), gzipName),
+		Name: gzipName,
+		Hash: hash,
+		Mode: "0644",
+	}, nil
+}
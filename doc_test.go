@@ -0,0 +1,97 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareDocFileInstallsIntoDocDir(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "README.md")
+	writeTestFile(t, name, "# foo\n", 0644)
+
+	doc, err := prepareDocFile(name, "foo-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Path != "usr/share/doc/foo-git/README.md" || doc.Mode != "0644" {
+		t.Fatalf("unexpected doc file: %+v", doc)
+	}
+
+	contents := renderPkgbuild(t, pkgData{
+		PkgName: "foo-git",
+		Files:   []pkgFile{doc},
+	})
+
+	assertContains(
+		t, contents,
+		"install -DT -m0644 \"$srcdir/README.md\" "+
+			"\"$pkgdir/usr/share/doc/foo-git/README.md\"\n",
+	)
+}
+
+func TestCreateGzipDocCompressesLargeDoc(t *testing.T) {
+	dir, outDir := t.TempDir(), t.TempDir()
+
+	small := filepath.Join(dir, "README.md")
+	writeTestFile(t, small, "# foo\n", 0644)
+
+	text := strings.Repeat("foo finds files fast.\n", 1000)
+
+	large := filepath.Join(dir, "MANUAL.md")
+	writeTestFile(t, large, text, 0644)
+
+	isCompressible, err := isDocCompressible(small)
+	if err != nil || isCompressible {
+		t.Fatalf("expected small doc not to be compressed, got error: %v", err)
+	}
+
+	isCompressible, err = isDocCompressible(large)
+	if err != nil || !isCompressible {
+		t.Fatalf("expected large doc to be compressed, got error: %v", err)
+	}
+
+	doc, err := createGzipDoc(large, outDir, "foo", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Path != "usr/share/doc/foo/MANUAL.md.gz" ||
+		doc.Name != "MANUAL.md.gz" {
+		t.Fatalf("unexpected doc file: %+v", doc)
+	}
+
+	again, err := createGzipDoc(large, t.TempDir(), "foo", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.Hash != doc.Hash {
+		t.Fatalf("expected reproducible gzip, got %q and %q", doc.Hash, again.Hash)
+	}
+
+	file, err := os.Open(filepath.Join(outDir, doc.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != text {
+		t.Fatal("unexpected decompressed contents")
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import "text/template"
+
+var dropinTemplate = template.Must(
+	template.New("dropin").Parse(`[Service]
+{{- range .Overrides}}
+{{.Name}}={{.Value}}
+{{- end}}
+`))
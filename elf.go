@@ -0,0 +1,117 @@
+package main
+
+import (
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// libraryDirs are searched for shared libraries required by built binaries
+// to find packages which own them.
+var libraryDirs = []string{"/usr/lib", "/usr/lib64", "/lib", "/lib64"}
+
+// implicitDependencies are not suggested, since they are required by almost
+// every binary and are always installed.
+var implicitDependencies = []string{"glibc"}
+
+// getELFNeeded returns shared libraries listed in NEEDED entries of ELF
+// binary. Statically linked binaries have no such entries.
+func getELFNeeded(path string) ([]string, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return file.ImportedLibraries()
+}
+
+// getBuiltBinaries returns executables installed into package directory by
+// makepkg, which is available only when build is not cleaned up.
+func getBuiltBinaries(
+	dir string, pkgName string, binDir string,
+) ([]string, error) {
+	binaries, err := filepath.Glob(
+		filepath.Join(dir, "pkg", pkgName, binDir, "*"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(binaries)
+
+	return binaries, nil
+}
+
+// suggestDependencies finds packages owning shared libraries required by
+// specified binaries. Libraries which are not found or not owned by any
+// package are returned separately.
+func suggestDependencies(binaries []string) ([]string, []string, error) {
+	packages := []string{}
+	unresolved := []string{}
+
+	for _, binary := range binaries {
+		libraries, err := getELFNeeded(binary)
+		if err != nil {
+			if _, ok := err.(*elf.FormatError); ok {
+				continue
+			}
+
+			return nil, nil, err
+		}
+
+		for _, library := range libraries {
+			owner := getLibraryOwner(library)
+			if owner == "" {
+				if !isStringInList(library, unresolved) {
+					unresolved = append(unresolved, library)
+				}
+
+				continue
+			}
+
+			if isStringInList(owner, implicitDependencies) {
+				continue
+			}
+
+			if !isStringInList(owner, packages) {
+				packages = append(packages, owner)
+			}
+		}
+	}
+
+	sort.Strings(packages)
+	sort.Strings(unresolved)
+
+	return packages, unresolved, nil
+}
+
+// getLibraryOwner returns name of the installed package which owns specified
+// shared library using 'pacman -Qqo', or empty string if it can't be found.
+func getLibraryOwner(library string) string {
+	for _, dir := range libraryDirs {
+		path := filepath.Join(dir, library)
+
+		_, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		ctx, cancel := newTimeoutContext(commandTimeout)
+		defer cancel()
+
+		output, err := execCommandContext(
+			ctx, "pacman", "-Qqo", path,
+		).Output()
+		if err != nil {
+			return ""
+		}
+
+		return strings.TrimSpace(string(output))
+	}
+
+	return ""
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// neededFixture is dynamically linked binary built from needed.c with
+// 'gcc -Os -s -Wl,--no-as-needed -o needed needed.c -lm'.
+var neededFixture = filepath.Join("testdata", "elf", "needed")
+
+func TestGetELFNeededReadsFixture(t *testing.T) {
+	libraries, err := getELFNeeded(neededFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"libm.so.6", "libc.so.6"}
+	if !reflect.DeepEqual(libraries, expected) {
+		t.Fatalf("expected %q, got %q", expected, libraries)
+	}
+}
+
+func TestSuggestDependenciesOfFixture(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "libm.so.6"), "", 0644)
+
+	defaultDirs := libraryDirs
+	libraryDirs = []string{dir}
+	defer func() { libraryDirs = defaultDirs }()
+
+	commands := fakeCommands(t, "echo libm-provider")
+
+	packages, unresolved, err := suggestDependencies([]string{
+		neededFixture, filepath.Join("testdata", "elf", "needed.c"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(packages, []string{"libm-provider"}) {
+		t.Fatalf("unexpected packages: %q", packages)
+	}
+
+	if !reflect.DeepEqual(unresolved, []string{"libc.so.6"}) {
+		t.Fatalf("unexpected unresolved libraries: %q", unresolved)
+	}
+
+	expected := [][]string{
+		{"pacman", "-Qqo", filepath.Join(dir, "libm.so.6")},
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, *commands)
+	}
+}
+
+func TestSuggestDependenciesSkipsImplicitAndDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "libm.so.6"), "", 0644)
+	writeTestFile(t, filepath.Join(dir, "libc.so.6"), "", 0644)
+
+	defaultDirs := libraryDirs
+	libraryDirs = []string{dir}
+	defer func() { libraryDirs = defaultDirs }()
+
+	fakeCommands(t, `case "$2" in
+		*/libc.so.6) echo glibc;;
+		*) echo libm;;
+	esac`)
+
+	packages, unresolved, err := suggestDependencies([]string{
+		neededFixture, neededFixture,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(packages, []string{"libm"}) {
+		t.Fatalf("expected only libm to be suggested, got %q", packages)
+	}
+
+	if len(unresolved) != 0 {
+		t.Fatalf("unexpected unresolved libraries: %q", unresolved)
+	}
+}
+
+func TestGetBuiltBinariesFindsPackagedFixture(t *testing.T) {
+	contents, err := ioutil.ReadFile(neededFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "pkg", "foo", "usr", "bin", "foo")
+	writeTestFile(t, binary, string(contents), 0755)
+
+	binaries, err := getBuiltBinaries(dir, "foo", "usr/bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(binaries, []string{binary}) {
+		t.Fatalf("expected %q, got %q", []string{binary}, binaries)
+	}
+
+	libraries, err := getELFNeeded(binaries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(libraries, []string{"libm.so.6", "libc.so.6"}) {
+		t.Fatalf("unexpected libraries: %q", libraries)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkRepo ensures that remote repository exists and is accessible.
+func checkRepo(repoURL string) error {
+	timeout := getTimeout(preflightTimeout)
+
+	ctx, cancel := newTimeoutContext(timeout)
+	defer cancel()
+
+	cmd := execCommandContext(
+		ctx, "git", "ls-remote", "--exit-code", repoURL, "HEAD",
+	)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(
+			"repo %s is not accessible: %s\n%s",
+			repoURL, getTimeoutError(ctx, timeout, err),
+			strings.TrimSpace(string(output)),
+		)
+	}
+
+	return nil
+}
+
+// repoShorthands maps repo URL shorthand prefixes to URLs of hosts.
+var repoShorthands = []struct {
+	Prefix string
+	URL    string
+}{
+	{"github:", "https://github.com/"},
+	{"gitlab:", "https://gitlab.com/"},
+	{"sr.ht:", "https://git.sr.ht/"},
+}
+
+// expandRepoShorthand expands repo URL shorthand like 'github:user/repo' to
+// full URL. Other URLs are returned as is.
+func expandRepoShorthand(repo string) string {
+	for _, shorthand := range repoShorthands {
+		if strings.HasPrefix(repo, shorthand.Prefix) {
+			return shorthand.URL + strings.TrimPrefix(
+				strings.TrimPrefix(repo, shorthand.Prefix), "/",
+			)
+		}
+	}
+
+	return repo
+}
+
+// scpLikeURLRegexp matches scp-like syntax of git repo URL, like
+// 'git@github.com:user/repo.git', which has no scheme.
+var scpLikeURLRegexp = regexp.MustCompile(`^([^@/:]+)@([^@/:]+):([^/].*)$`)
+
+// convertScpLikeURL converts scp-like git repo URL to equivalent URL with
+// 'ssh' scheme. Other URLs are returned as is.
+func convertScpLikeURL(repo string) string {
+	matches := scpLikeURLRegexp.FindStringSubmatch(repo)
+	if matches == nil {
+		return repo
+	}
+
+	return "ssh://" + matches[1] + "@" + matches[2] + "/" + matches[3]
+}
+
+func isLocalRepoURL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "file://") ||
+		strings.HasPrefix(repoURL, "/") ||
+		strings.HasPrefix(repoURL, ".")
+}
+
+// defaultBranchCacheTTL limits how long detected default branch is reused
+// without querying remote repository again.
+const defaultBranchCacheTTL = 24 * time.Hour
+
+// getDefaultBranchCachePath returns path of file, where detected default
+// branches are stored between runs.
+func getDefaultBranchCachePath() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+
+	return filepath.Join(cacheHome, "go-makepkg", "default-branches")
+}
+
+// readCachedDefaultBranch returns default branch of repo stored in cache
+// file, or empty string if there is no entry or it's older than TTL. Cache
+// file contains lines in form '<URL> <BRANCH> <UNIX TIME>'.
+func readCachedDefaultBranch(
+	cachePath string, repoURL string, now time.Time,
+) string {
+	contents, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != repoURL {
+			continue
+		}
+
+		timestamp, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil ||
+			now.Sub(time.Unix(timestamp, 0)) > defaultBranchCacheTTL {
+			return ""
+		}
+
+		return fields[1]
+	}
+
+	return ""
+}
+
+// writeCachedDefaultBranch stores default branch of repo in cache file,
+// replacing previous entry of the repo.
+func writeCachedDefaultBranch(
+	cachePath string, repoURL string, branch string, now time.Time,
+) error {
+	lines := []string{}
+
+	contents, err := ioutil.ReadFile(cachePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] != repoURL {
+			lines = append(lines, line)
+		}
+	}
+
+	lines = append(
+		lines, fmt.Sprintf("%s %s %d", repoURL, branch, now.Unix()),
+	)
+
+	err = os.MkdirAll(filepath.Dir(cachePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(
+		cachePath, []byte(strings.Join(lines, "\n")+"\n"), 0644,
+	)
+}
+
+// getRemoteDefaultBranch returns branch which HEAD of the remote repository
+// points to. Result is cached on disk, so remote is not queried on every
+// run.
+func getRemoteDefaultBranch(repoURL string) (string, error) {
+	cachePath := getDefaultBranchCachePath()
+
+	branch := readCachedDefaultBranch(cachePath, repoURL, time.Now())
+	if branch != "" {
+		return branch, nil
+	}
+
+	timeout := getTimeout(preflightTimeout)
+
+	ctx, cancel := newTimeoutContext(timeout)
+	defer cancel()
+
+	cmd := execCommandContext(
+		ctx, "git", "ls-remote", "--symref", repoURL, "HEAD",
+	)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf(
+			"can't query %s: %s",
+			repoURL, getTimeoutError(ctx, timeout, err),
+		)
+	}
+
+	branch = parseSymrefHead(string(output))
+	if branch == "" {
+		return "", fmt.Errorf("can't find default branch of %s", repoURL)
+	}
+
+	err = writeCachedDefaultBranch(cachePath, repoURL, branch, time.Now())
+	if err != nil {
+		logWarning("Can't cache default branch: %s", err)
+	}
+
+	return branch, nil
+}
+
+// parseSymrefHead extracts branch name from 'git ls-remote --symref' output
+// line like 'ref: refs/heads/master<TAB>HEAD'.
+func parseSymrefHead(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "ref:" || fields[2] != "HEAD" {
+			continue
+		}
+
+		return strings.TrimPrefix(fields[1], "refs/heads/")
+	}
+
+	return ""
+}
+
+// getPinnedRef returns ref pinned to commit, which branch of the remote
+// repository currently points to. Refs of tag or commit kind are returned as
+// is, as well as branch ref, which can't be pinned.
+func getPinnedRef(
+	repoURL string, refKind string, refName string, defaultBranch string,
+) (string, string) {
+	if refKind == "tag" || refKind == "commit" {
+		return refKind, refName
+	}
+
+	branch := refName
+	if refKind == "" {
+		branch = defaultBranch
+	}
+
+	logStep("Pinning branch %s to commit...", branch)
+
+	commit, err := getRemoteBranchCommit(repoURL, branch)
+	if err != nil {
+		logWarning("Can't pin branch, source is not pinned: %s", err)
+		return refKind, refName
+	}
+
+	logSubStep("Using commit: %s", commit)
+
+	return "commit", commit
+}
+
+// getRemoteBranchCommit returns hash of the commit which specified branch of
+// the remote repository points to.
+func getRemoteBranchCommit(repoURL string, branch string) (string, error) {
+	timeout := getTimeout(preflightTimeout)
+
+	ctx, cancel := newTimeoutContext(timeout)
+	defer cancel()
+
+	cmd := execCommandContext(
+		ctx, "git", "ls-remote", "--exit-code", repoURL, "refs/heads/"+branch,
+	)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf(
+			"can't query branch %s of %s: %s",
+			branch, repoURL, getTimeoutError(ctx, timeout, err),
+		)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("can't find branch %s of %s", branch, repoURL)
+	}
+
+	return fields[0], nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConvertScpLikeURL(t *testing.T) {
+	tests := []struct {
+		repo     string
+		expected string
+	}{
+		{"git@github.com:user/repo.git", "ssh://git@github.com/user/repo.git"},
+		{"user@git.example.com:repo", "ssh://user@git.example.com/repo"},
+		{"https://github.com/user/repo", "https://github.com/user/repo"},
+		{"ssh://git@github.com:22/user/repo", "ssh://git@github.com:22/user/repo"},
+		{"/home/user/repo", "/home/user/repo"},
+	}
+
+	for _, test := range tests {
+		repoURL := convertScpLikeURL(test.repo)
+		if repoURL != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.repo, test.expected, repoURL)
+		}
+	}
+}
+
+func TestExpandRepoShorthand(t *testing.T) {
+	tests := []struct {
+		repo        string
+		expected    string
+		packageName string
+	}{
+		{"github:user/tool", "https://github.com/user/tool", "tool"},
+		{"gitlab:user/tool.git", "https://gitlab.com/user/tool.git", "tool"},
+		{"sr.ht:~user/tool", "https://git.sr.ht/~user/tool", "tool"},
+		{"github:/user/tool", "https://github.com/user/tool", "tool"},
+		{"git@github.com:user/tool.git", "git@github.com:user/tool.git", "tool"},
+		{"https://example.com/tool", "https://example.com/tool", "tool"},
+	}
+
+	for _, test := range tests {
+		repoURL := expandRepoShorthand(test.repo)
+		if repoURL != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.repo, test.expected, repoURL)
+		}
+
+		packageName := getPackageNameFromRepoURL(
+			convertScpLikeURL(repoURL),
+		)
+		if packageName != test.packageName {
+			t.Errorf(
+				"%s: expected package name %q, got %q",
+				test.repo, test.packageName, packageName,
+			)
+		}
+	}
+}
+
+func TestGetRemoteDefaultBranchUsesQueriedBranch(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	var queried []string
+	execCommandContext = func(
+		ctx context.Context, name string, args ...string,
+	) *exec.Cmd {
+		queried = append(
+			queried, strings.Join(append([]string{name}, args...), " "),
+		)
+
+		return exec.CommandContext(
+			ctx, "printf", `ref: refs/heads/main\tHEAD\nabc\tHEAD\n`,
+		)
+	}
+	defer func() { execCommandContext = exec.CommandContext }()
+
+	for i := 0; i < 2; i++ {
+		branch, err := getRemoteDefaultBranch("https://example.com/repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if branch != "main" {
+			t.Errorf("expected %q, got %q", "main", branch)
+		}
+	}
+
+	expected := []string{
+		"git ls-remote --symref https://example.com/repo HEAD",
+	}
+	if !reflect.DeepEqual(queried, expected) {
+		t.Errorf("expected single query %q, got %q", expected, queried)
+	}
+}
+
+func TestReadCachedDefaultBranchExpires(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "default-branches")
+	now := time.Unix(1600000000, 0)
+
+	err := writeCachedDefaultBranch(cachePath, "https://a/repo", "main", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeCachedDefaultBranch(cachePath, "https://b/repo", "trunk", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branch := readCachedDefaultBranch(cachePath, "https://a/repo", now)
+	if branch != "main" {
+		t.Errorf("expected %q, got %q", "main", branch)
+	}
+
+	branch = readCachedDefaultBranch(cachePath, "https://b/repo", now)
+	if branch != "trunk" {
+		t.Errorf("expected %q, got %q", "trunk", branch)
+	}
+
+	branch = readCachedDefaultBranch(
+		cachePath, "https://a/repo", now.Add(defaultBranchCacheTTL+time.Second),
+	)
+	if branch != "" {
+		t.Errorf("expected expired entry to be ignored, got %q", branch)
+	}
+}
+
+func TestCheckRepo(t *testing.T) {
+	tests := []struct {
+		script     string
+		accessible bool
+	}{
+		{"printf 'abc\\tHEAD\\n'", true},
+		{"echo 'fatal: repository not found' >&2; exit 128", false},
+	}
+
+	for _, test := range tests {
+		commands := fakeCommands(t, test.script)
+
+		err := checkRepo("https://example.com/repo")
+		if (err == nil) != test.accessible {
+			t.Errorf(
+				"%q: expected accessible: %t, got error: %v",
+				test.script, test.accessible, err,
+			)
+		}
+
+		expected := [][]string{{
+			"git", "ls-remote", "--exit-code",
+			"https://example.com/repo", "HEAD",
+		}}
+		if !reflect.DeepEqual(*commands, expected) {
+			t.Errorf("expected %q, got %q", expected, *commands)
+		}
+	}
+}
+
+func TestIsLocalRepoURL(t *testing.T) {
+	tests := []struct {
+		repo     string
+		expected bool
+	}{
+		{"/home/user/repo", true},
+		{"./repo", true},
+		{"file:///home/user/repo", true},
+		{"https://github.com/user/repo", false},
+		{"ssh://git@github.com/user/repo", false},
+	}
+
+	for _, test := range tests {
+		if isLocalRepoURL(test.repo) != test.expected {
+			t.Errorf("%s: expected local: %t", test.repo, test.expected)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import "text/template"
+
+var gitignoreTemplate = template.Must(
+	template.New("gitignore").Parse(`/*.tar.xz
+/pkg
+/src
+/{{.PkgName}}
+`))
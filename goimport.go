@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const goImportTimeout = 10 * time.Second
+
+var (
+	goImportMetaRegexp    = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	goImportNameRegexp    = regexp.MustCompile(`(?i)\sname\s*=\s*["']go-import["']`)
+	goImportContentRegexp = regexp.MustCompile(
+		`(?i)\scontent\s*=\s*["']([^"']*)["']`,
+	)
+)
+
+// gopathImportDir returns directory under GOPATH source tree for repo URL,
+// like 'github.com/user/repo' for 'https://github.com/user/repo.git' or
+// 'git@github.com:user/repo.git', or empty string for local repos and URLs
+// without host.
+func gopathImportDir(repoURL string) string {
+	if isLocalRepoURL(repoURL) {
+		return ""
+	}
+
+	repoURL = convertScpLikeURL(repoURL)
+
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil || parsedURL.Hostname() == "" {
+		return ""
+	}
+
+	repoPath := strings.Trim(strings.TrimSuffix(parsedURL.Path, ".git"), "/")
+	if repoPath == "" {
+		return ""
+	}
+
+	return parsedURL.Hostname() + "/" + repoPath
+}
+
+// isGoImportPath reports whether repo is specified as Go import path like
+// 'gopkg.in/yaml.v2' instead of URL or local path.
+func isGoImportPath(repo string) bool {
+	if strings.Contains(repo, "://") || strings.HasPrefix(repo, "/") ||
+		strings.HasPrefix(repo, ".") || strings.Contains(repo, ":") {
+		return false
+	}
+
+	return strings.Contains(strings.SplitN(repo, "/", 2)[0], ".")
+}
+
+// resolveGoImportPath fetches go-import meta tag for specified import path
+// and returns import path prefix of repo root along with git repo URL.
+func resolveGoImportPath(
+	baseURL string, importPath string,
+) (string, string, error) {
+	client := http.Client{Timeout: getTimeout(goImportTimeout)}
+
+	response, err := client.Get(baseURL + importPath + "?go-get=1")
+	if err != nil {
+		return "", "", err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf(
+			"can't resolve import path %s: %s", importPath, response.Status,
+		)
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(response.Body, 1024*1024))
+	if err != nil {
+		return "", "", err
+	}
+
+	return parseGoImportMeta(string(contents), importPath)
+}
+
+// parseGoImportMeta finds go-import meta tag with git VCS which prefix
+// matches specified import path.
+func parseGoImportMeta(page string, importPath string) (string, string, error) {
+	for _, tag := range goImportMetaRegexp.FindAllString(page, -1) {
+		if !goImportNameRegexp.MatchString(tag) {
+			continue
+		}
+
+		content := goImportContentRegexp.FindStringSubmatch(tag)
+		if content == nil {
+			continue
+		}
+
+		fields := strings.Fields(content[1])
+		if len(fields) != 3 || fields[1] != "git" {
+			continue
+		}
+
+		prefix, repoURL := fields[0], fields[2]
+		if importPath != prefix && !strings.HasPrefix(importPath, prefix+"/") {
+			continue
+		}
+
+		return prefix, repoURL, nil
+	}
+
+	return "", "", fmt.Errorf(
+		"no go-import meta tag with git repo found for %s", importPath,
+	)
+}
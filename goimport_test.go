@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGopathImportDir(t *testing.T) {
+	tests := []struct {
+		repoURL  string
+		expected string
+	}{
+		{"https://github.com/user/repo.git", "github.com/user/repo"},
+		{"https://github.com/user/repo/", "github.com/user/repo"},
+		{"https://gitlab.com/group/subgroup/repo", "gitlab.com/group/subgroup/repo"},
+		{"https://git.example.com:8443/tools/repo.git", "git.example.com/tools/repo"},
+		{"git://git.example.com/repo", "git.example.com/repo"},
+		{"ssh://git@github.com/user/repo.git", "github.com/user/repo"},
+		{"git@github.com:user/repo.git", "github.com/user/repo"},
+		{"git@gitlab.com:group/repo", "gitlab.com/group/repo"},
+		{"/home/user/repo", ""},
+		{"file:///home/user/repo", ""},
+		{"https://github.com/", ""},
+	}
+
+	for _, test := range tests {
+		importDir := gopathImportDir(test.repoURL)
+		if importDir != test.expected {
+			t.Errorf(
+				"%s: expected %q, got %q", test.repoURL, test.expected, importDir,
+			)
+		}
+	}
+}
+
+func TestResolveGoImportPathUsesMetaTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Query().Get("go-get") != "1" {
+				http.NotFound(writer, request)
+				return
+			}
+
+			fmt.Fprint(writer, `<html><head>
+<meta name="go-source" content="vanity.example/yaml.v2 _ _ _">
+<meta name="go-import"
+	content="vanity.example/yaml.v2 git https://git.example.com/yaml">
+</head></html>`)
+		},
+	))
+	defer server.Close()
+
+	tests := []struct {
+		importPath string
+	}{
+		{"vanity.example/yaml.v2"},
+		{"vanity.example/yaml.v2/cmd/yaml"},
+	}
+
+	for _, test := range tests {
+		prefix, repoURL, err := resolveGoImportPath(
+			server.URL+"/", test.importPath,
+		)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.importPath, err)
+			continue
+		}
+
+		if prefix != "vanity.example/yaml.v2" {
+			t.Errorf(
+				"%s: expected prefix %q, got %q",
+				test.importPath, "vanity.example/yaml.v2", prefix,
+			)
+		}
+
+		if repoURL != "https://git.example.com/yaml" {
+			t.Errorf(
+				"%s: expected repo %q, got %q",
+				test.importPath, "https://git.example.com/yaml", repoURL,
+			)
+		}
+	}
+}
+
+func TestResolveGoImportPathFailsWithoutGitMetaTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Path == "/missing.example/pkg" {
+				http.NotFound(writer, request)
+				return
+			}
+
+			fmt.Fprint(writer, `<meta name="go-import" `+
+				`content="hg.example/pkg hg https://hg.example.com/pkg">`)
+		},
+	))
+	defer server.Close()
+
+	for _, importPath := range []string{"missing.example/pkg", "hg.example/pkg"} {
+		_, _, err := resolveGoImportPath(server.URL+"/", importPath)
+		if err == nil {
+			t.Errorf("%s: expected error", importPath)
+		}
+	}
+}
+
+func TestIsGoImportPath(t *testing.T) {
+	tests := []struct {
+		repo     string
+		expected bool
+	}{
+		{"gopkg.in/yaml.v2", true},
+		{"rsc.io/quote", true},
+		{"https://github.com/user/repo", false},
+		{"git@github.com:user/repo.git", false},
+		{"github:user/repo", false},
+		{"./repo", false},
+		{"user/repo", false},
+	}
+
+	for _, test := range tests {
+		if isGoImportPath(test.repo) != test.expected {
+			t.Errorf("%s: expected import path: %t", test.repo, test.expected)
+		}
+	}
+}
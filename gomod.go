@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// parseGoModRequires returns modules required by go.mod file in form
+// '<module> <version>', marking indirect ones.
+func parseGoModRequires(name string) ([]string, error) {
+	contents, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	requires := []string{}
+	isInBlock := false
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+
+		isIndirect := false
+		if index := strings.Index(line, "//"); index >= 0 {
+			isIndirect = strings.TrimSpace(line[index+2:]) == "indirect"
+			line = strings.TrimSpace(line[:index])
+		}
+
+		switch {
+		case isInBlock && line == ")":
+			isInBlock = false
+			continue
+
+		case line == "require (":
+			isInBlock = true
+			continue
+
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+
+		case !isInBlock:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		require := strings.Join(fields, " ")
+		if isIndirect {
+			require += " (indirect)"
+		}
+
+		requires = append(requires, require)
+	}
+
+	return requires, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGoModRequires(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "go.mod")
+
+	err := ioutil.WriteFile(name, []byte(`module example.com/tool
+
+go 1.16
+
+require github.com/docopt/docopt-go v0.0.0-20180111231733-ee0de3bc6815
+
+require (
+	github.com/kovetskiy/lorg v1.2.0
+	// pinned until upstream is fixed
+	github.com/reconquest/karma-go v1.3.1 // indirect
+)
+
+replace github.com/kovetskiy/lorg => ../lorg
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requires, err := parseGoModRequires(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"github.com/docopt/docopt-go v0.0.0-20180111231733-ee0de3bc6815",
+		"github.com/kovetskiy/lorg v1.2.0",
+		"github.com/reconquest/karma-go v1.3.1 (indirect)",
+	}
+	if !reflect.DeepEqual(requires, expected) {
+		t.Fatalf("expected %q, got %q", expected, requires)
+	}
+}
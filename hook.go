@@ -0,0 +1,18 @@
+package main
+
+import "text/template"
+
+var completionHookTemplate = template.Must(
+	template.New("completion-hook").Parse(`[Trigger]
+Type = Path
+Operation = Install
+Operation = Upgrade
+Operation = Remove
+Target = {{.CompletionDir}}/*
+
+[Action]
+Description = Rebuilding zsh completion dump...
+When = PostTransaction
+Depends = zsh
+Exec = /usr/bin/zsh -fc 'mkdir -p {{.DumpDir}} && autoload -Uz compinit && compinit -u -d {{.DumpDir}}/zcompdump'
+`))
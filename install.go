@@ -0,0 +1,59 @@
+package main
+
+import "text/template"
+
+var installTemplate = template.Must(
+	template.New("install").Parse(
+		`# Install scriptlet for {{.PkgName}} package.
+{{if or (and .Units .EnableUnits) .AppArmorProfile .Chowns}}
+post_install() {
+{{- if .AppArmorProfile}}
+	reload_apparmor_profile
+{{- end}}
+{{- if .Chowns}}
+	fix_ownership
+{{- end}}
+{{- if .EnableUnits}}{{range .Units}}
+	systemctl {{if $.IsUserUnits}}--global {{end}}enable {{.}}
+{{- end}}{{end}}
+}
+{{end}}{{if or .AppArmorProfile .Chowns}}
+post_upgrade() {
+{{- if .AppArmorProfile}}
+	reload_apparmor_profile
+{{- end}}
+{{- if .Chowns}}
+	fix_ownership
+{{- end}}
+}
+{{end}}{{if .AppArmorProfile}}
+reload_apparmor_profile() {
+	if [ -d /sys/kernel/security/apparmor ]; then
+		apparmor_parser -r /{{.AppArmorProfile}} || true
+	fi
+}
+{{end}}{{if .Chowns}}
+fix_ownership() {
+{{- range .Chowns}}
+	chown {{.User}}:{{.Group}} "/{{.Path}}"
+{{- end}}
+}
+{{end}}{{if and .Units .IsUserUnits}}
+post_remove() {
+{{- range .Units}}
+	systemctl --global disable {{.}} 2>/dev/null || true
+{{- end}}
+}
+{{else if .Units}}
+pre_remove() {
+	if [ -d /run/systemd/system ]; then{{range .Units}}
+		systemctl stop {{.}} || true{{end}}
+	fi
+}
+
+post_remove() {
+	if [ -d /run/systemd/system ]; then{{range .Units}}
+		systemctl disable --now {{.}} 2>/dev/null || true{{end}}
+	fi
+}
+{{end}}`))
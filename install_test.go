@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// renderInstallScript renders install scriptlet for specified data.
+func renderInstallScript(t *testing.T, data installData) string {
+	t.Helper()
+
+	contents := &strings.Builder{}
+	err := createInstallScript(contents, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return contents.String()
+}
+
+func TestInstallScriptStopsAndDisablesUnitsOnRemove(t *testing.T) {
+	contents := renderInstallScript(t, installData{
+		PkgName: "foo",
+		Units:   []string{"foo-daemon.service"},
+	})
+
+	assertContains(
+		t, contents,
+		"pre_remove() {\n"+
+			"\tif [ -d /run/systemd/system ]; then\n"+
+			"\t\tsystemctl stop foo-daemon.service || true\n"+
+			"\tfi\n"+
+			"}\n",
+		"post_remove() {\n"+
+			"\tif [ -d /run/systemd/system ]; then\n"+
+			"\t\tsystemctl disable --now foo-daemon.service "+
+			"2>/dev/null || true\n"+
+			"\tfi\n"+
+			"}\n",
+	)
+}
+
+func TestInstallScriptDisablesUserUnitsOnRemove(t *testing.T) {
+	contents := renderInstallScript(t, installData{
+		PkgName:     "foo",
+		Units:       []string{"foo.service"},
+		IsUserUnits: true,
+	})
+
+	assertContains(
+		t, contents,
+		"\tsystemctl --global disable foo.service 2>/dev/null || true\n",
+	)
+
+	if strings.Contains(contents, "pre_remove") {
+		t.Fatalf("expected no pre_remove for user units in:\n%s", contents)
+	}
+}
+
+func TestInstallScriptEnablesUnitsOnlyWhenRequested(t *testing.T) {
+	contents := renderInstallScript(t, installData{
+		PkgName:     "foo",
+		Units:       []string{"foo.service"},
+		EnableUnits: true,
+	})
+
+	assertContains(
+		t, contents,
+		"post_install() {\n\tsystemctl enable foo.service\n}\n",
+	)
+
+	if strings.Contains(contents, "post_upgrade") {
+		t.Fatalf("expected no post_upgrade in:\n%s", contents)
+	}
+
+	contents = renderInstallScript(t, installData{
+		PkgName: "foo",
+		Units:   []string{"foo.service"},
+	})
+
+	if strings.Contains(contents, "enable") ||
+		strings.Contains(contents, "post_install") {
+		t.Fatalf("expected no enabling of units in:\n%s", contents)
+	}
+}
+
+func TestInstallScriptReloadsAppArmorProfile(t *testing.T) {
+	contents := renderInstallScript(t, installData{
+		PkgName:         "foo",
+		AppArmorProfile: "etc/apparmor.d/foo",
+	})
+
+	assertContains(
+		t, contents,
+		"post_install() {\n\treload_apparmor_profile\n}\n",
+		"post_upgrade() {\n\treload_apparmor_profile\n}\n",
+		"\t\tapparmor_parser -r /etc/apparmor.d/foo || true\n",
+	)
+}
+
+func TestInstallScriptFixesOwnershipOfConfiguredPaths(t *testing.T) {
+	chowns, err := parseChowns([]string{
+		"var/lib/foo:foo:foo",
+		"etc/foo/secret.conf:root:foo",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := renderInstallScript(t, installData{
+		PkgName: "foo",
+		Chowns:  chowns,
+	})
+
+	assertContains(
+		t, contents,
+		"post_install() {\n\tfix_ownership\n}\n",
+		"post_upgrade() {\n\tfix_ownership\n}\n",
+		"fix_ownership() {\n"+
+			"\tchown foo:foo \"/var/lib/foo\"\n"+
+			"\tchown root:foo \"/etc/foo/secret.conf\"\n"+
+			"}\n",
+	)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const licenseMaxSize = 1024 * 1024
+
+// licenseFileNames are tried in order when fetching license from repo host.
+var licenseFileNames = []string{
+	"LICENSE", "LICENSE.md", "LICENSE.txt", "COPYING",
+}
+
+var licenseFileRegexp = regexp.MustCompile(
+	`(?i)^(LICEN[CS]E|COPYING)([-._].*)?$`,
+)
+
+// licenseHeadSize limits part of normalized license text, where license
+// title or preamble should be found. License bodies often mention other
+// licenses, like GPL-3.0 mentions AGPL-3.0, so they are not searched.
+const licenseHeadSize = 1000
+
+type licenseSignature struct {
+	License string
+	Title   *regexp.Regexp
+	Markers []string
+}
+
+// licenseSignatures are matched by title found first in license head, so
+// notice preceding license text doesn't affect detection. Signatures with
+// same title are checked in order, so more specific ones come first.
+var licenseSignatures = []licenseSignature{
+	{
+		"AGPL-3.0",
+		regexp.MustCompile(`GNU AFFERO GENERAL PUBLIC LICENSE VERSION 3\b`),
+		nil,
+	},
+	{
+		"LGPL-3.0",
+		regexp.MustCompile(`GNU LESSER GENERAL PUBLIC LICENSE VERSION 3\b`),
+		nil,
+	},
+	{
+		"LGPL-2.1",
+		regexp.MustCompile(`GNU LESSER GENERAL PUBLIC LICENSE VERSION 2\.1\b`),
+		nil,
+	},
+	{
+		"GPL-3.0",
+		regexp.MustCompile(`GNU GENERAL PUBLIC LICENSE VERSION 3\b`),
+		nil,
+	},
+	{
+		"GPL-2.0",
+		regexp.MustCompile(`GNU GENERAL PUBLIC LICENSE VERSION 2\b`),
+		nil,
+	},
+	{
+		"Apache-2.0",
+		regexp.MustCompile(`APACHE LICENSE,? VERSION 2\.0\b`),
+		nil,
+	},
+	{
+		"MPL-2.0",
+		regexp.MustCompile(`MOZILLA PUBLIC LICENSE,? VERSION 2\.0\b`),
+		nil,
+	},
+	{
+		"MIT",
+		regexp.MustCompile(`PERMISSION IS HEREBY GRANTED, FREE OF CHARGE`),
+		nil,
+	},
+	{
+		"BSD-3-Clause",
+		regexp.MustCompile(`REDISTRIBUTION AND USE IN SOURCE AND BINARY FORMS`),
+		[]string{"NEITHER THE NAME"},
+	},
+	{
+		"BSD-2-Clause",
+		regexp.MustCompile(`REDISTRIBUTION AND USE IN SOURCE AND BINARY FORMS`),
+		nil,
+	},
+	{
+		"Unlicense",
+		regexp.MustCompile(`THIS IS FREE AND UNENCUMBERED SOFTWARE`),
+		nil,
+	},
+}
+
+// unlicensedLicenses are special license values for code which has no license
+// or has unknown one, so there is no license file to install.
+var unlicensedLicenses = []string{"none", "unknown"}
+
+// getUnlicensedLicense returns special license value in canonical form if
+// licenses consist only of it, or empty string otherwise.
+func getUnlicensedLicense(licenses []string) string {
+	if len(licenses) != 1 {
+		return ""
+	}
+
+	license := strings.ToLower(strings.TrimSpace(licenses[0]))
+	if !isStringInList(license, unlicensedLicenses) {
+		return ""
+	}
+
+	return license
+}
+
+func isCustomLicense(licenses []string) bool {
+	for _, license := range licenses {
+		if license == "custom" || strings.HasPrefix(license, "custom:") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getRawFileURL returns URL of raw file contents at specified ref of the repo
+// for supported hosts, or empty string for others.
+func getRawFileURL(repoURL string, ref string, name string) string {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil {
+		return ""
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(parsedURL.Path, "/"), ".git")
+
+	switch parsedURL.Hostname() {
+	case "github.com":
+		return "https://raw.githubusercontent.com/" +
+			repoPath + "/" + ref + "/" + name
+	case "gitlab.com":
+		return "https://gitlab.com/" + repoPath + "/-/raw/" + ref + "/" + name
+	case "git.sr.ht":
+		return "https://git.sr.ht/" + repoPath + "/blob/" + ref + "/" + name
+	default:
+		return ""
+	}
+}
+
+// fetchRepoLicense downloads first found license file of the repo at
+// specified ref from repo host. Empty name is returned if there is no
+// license file.
+func fetchRepoLicense(repoURL string, ref string) (string, []byte, error) {
+	for _, name := range licenseFileNames {
+		fileURL := getRawFileURL(repoURL, ref, name)
+		if fileURL == "" {
+			return "", nil, fmt.Errorf("unsupported repo host: %s", repoURL)
+		}
+
+		contents, err := fetchFile(fileURL)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if contents != nil {
+			return name, contents, nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+// fetchFile returns contents of file at specified URL or nil if there is no
+// such file.
+func fetchFile(fileURL string) ([]byte, error) {
+	client := http.Client{Timeout: getTimeout(preflightTimeout)}
+
+	response, err := client.Get(fileURL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(io.LimitReader(response.Body, licenseMaxSize))
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("can't fetch %s: %s", fileURL, response.Status)
+	}
+}
+
+func findLicenseFile(names []string) string {
+	for _, name := range names {
+		if licenseFileRegexp.MatchString(filepath.Base(name)) {
+			return name
+		}
+	}
+
+	matches, _ := filepath.Glob("*")
+	for _, name := range matches {
+		if licenseFileRegexp.MatchString(name) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// detectLicenses detects licenses of given license files or, if none given,
+// of the license file found among package files or in the current directory.
+func detectLicenses(
+	fileList []string, licenseFiles []string, fallback []string,
+) ([]string, error) {
+	logStep("Detecting license...")
+
+	if len(licenseFiles) == 0 {
+		licenseFile := findLicenseFile(fileList)
+		if licenseFile == "" {
+			logWarning(
+				"No license file found, using license: %s",
+				strings.Join(fallback, ", "),
+			)
+
+			return fallback, nil
+		}
+
+		licenseFiles = []string{licenseFile}
+	}
+
+	licenses := []string{}
+	for _, licenseFile := range licenseFiles {
+		license, err := detectLicense(licenseFile)
+		if err != nil {
+			return nil, err
+		}
+
+		logSubStep("Detected license in %s: %s", licenseFile, license)
+
+		if !isStringInList(license, licenses) {
+			licenses = append(licenses, license)
+		}
+	}
+
+	return licenses, nil
+}
+
+func detectLicense(path string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return matchLicense(string(contents)), nil
+}
+
+// matchLicense returns license, which title is found first in the head of
+// license text and which markers are found anywhere in it, or 'custom' if
+// there is no such license.
+func matchLicense(text string) string {
+	text = strings.Join(strings.Fields(strings.ToUpper(text)), " ")
+
+	head := text
+	if len(head) > licenseHeadSize {
+		head = head[:licenseHeadSize]
+	}
+
+	license := "custom"
+	position := len(head)
+	for _, signature := range licenseSignatures {
+		match := signature.Title.FindStringIndex(head)
+		if match == nil || match[0] >= position {
+			continue
+		}
+
+		matched := true
+		for _, marker := range signature.Markers {
+			if !strings.Contains(text, marker) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			license, position = signature.License, match[0]
+		}
+	}
+
+	return license
+}
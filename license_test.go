@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchLicenseDetectsLicenseTexts(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("testdata", "licenses", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) == 0 {
+		t.Fatal("no license fixtures found")
+	}
+
+	for _, name := range names {
+		contents, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := filepath.Base(name)
+
+		license := matchLicense(string(contents))
+		if license != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, license)
+		}
+	}
+}
+
+func TestMatchLicenseIgnoresNoticeBeforeLicense(t *testing.T) {
+	contents, err := ioutil.ReadFile(
+		filepath.Join("testdata", "licenses", "GPL-3.0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := "tool - does things\nCopyright (C) 2020 John Doe\n\n" +
+		string(contents)
+
+	license := matchLicense(text)
+	if license != "GPL-3.0" {
+		t.Errorf("expected %q, got %q", "GPL-3.0", license)
+	}
+}
+
+func TestMatchLicenseReturnsCustomForUnknownText(t *testing.T) {
+	license := matchLicense("All rights reserved. Do not redistribute.")
+	if license != "custom" {
+		t.Errorf("expected %q, got %q", "custom", license)
+	}
+}
+
+func TestMatchLicenseIgnoresLicenseMentionedInBody(t *testing.T) {
+	text := "Permission is hereby granted, free of charge, to any person " +
+		"obtaining a copy of this software. Parts of it were previously " +
+		"distributed under GNU Affero General Public License version 3."
+
+	license := matchLicense(text)
+	if license != "MIT" {
+		t.Errorf("expected %q, got %q", "MIT", license)
+	}
+}
+
+func TestDetectLicensesOfDualLicensedPackage(t *testing.T) {
+	licenses, err := detectLicenses(nil, []string{
+		filepath.Join("testdata", "licenses", "MIT"),
+		filepath.Join("testdata", "licenses", "Apache-2.0"),
+	}, []string{"GPL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"MIT", "Apache-2.0"}
+	if !reflect.DeepEqual(licenses, expected) {
+		t.Fatalf("expected %q, got %q", expected, licenses)
+	}
+}
+
+func TestGetUnlicensedLicense(t *testing.T) {
+	tests := []struct {
+		licenses []string
+		expected string
+	}{
+		{[]string{"none"}, "none"},
+		{[]string{"Unknown"}, "unknown"},
+		{[]string{"MIT"}, ""},
+		{[]string{"none", "MIT"}, ""},
+	}
+
+	for _, test := range tests {
+		license := getUnlicensedLicense(test.licenses)
+		if license != test.expected {
+			t.Errorf(
+				"%q: expected %q, got %q", test.licenses, test.expected, license,
+			)
+		}
+
+		if isCustomLicense(test.licenses) {
+			t.Errorf("%q: expected non-custom license", test.licenses)
+		}
+	}
+}
+
+// hostRewriter sends every request to test server, keeping path, so code
+// fetching from real hosts can be tested.
+type hostRewriter struct {
+	server    *url.URL
+	transport http.RoundTripper
+}
+
+func (rewriter hostRewriter) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.URL.Scheme = rewriter.server.Scheme
+	request.URL.Host = rewriter.server.Host
+
+	return rewriter.transport.RoundTrip(request)
+}
+
+// serveHosts redirects all HTTP requests to specified handler until the end
+// of the test.
+func serveHosts(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = hostRewriter{serverURL, transport}
+
+	t.Cleanup(func() {
+		http.DefaultTransport = transport
+	})
+}
+
+func TestFetchRepoLicenseFindsLicenseFile(t *testing.T) {
+	requested := []string{}
+	serveHosts(t, func(writer http.ResponseWriter, request *http.Request) {
+		requested = append(requested, request.URL.Path)
+
+		if request.URL.Path != "/user/foo/master/COPYING" {
+			http.NotFound(writer, request)
+			return
+		}
+
+		fmt.Fprint(writer, "GNU GENERAL PUBLIC LICENSE\n")
+	})
+
+	name, contents, err := fetchRepoLicense(
+		"https://github.com/user/foo.git", "master",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "COPYING" ||
+		string(contents) != "GNU GENERAL PUBLIC LICENSE\n" {
+		t.Fatalf("unexpected license %q: %q", name, contents)
+	}
+
+	expected := []string{
+		"/user/foo/master/LICENSE",
+		"/user/foo/master/LICENSE.md",
+		"/user/foo/master/LICENSE.txt",
+		"/user/foo/master/COPYING",
+	}
+	if !reflect.DeepEqual(requested, expected) {
+		t.Fatalf("expected requests %q, got %q", expected, requested)
+	}
+}
+
+func TestFetchRepoLicenseWithoutLicenseFile(t *testing.T) {
+	serveHosts(t, http.NotFound)
+
+	name, contents, err := fetchRepoLicense(
+		"https://gitlab.com/user/foo", "main",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "" || contents != nil {
+		t.Fatalf("expected no license, got %q: %q", name, contents)
+	}
+}
+
+func TestFetchRepoLicenseFailsOnServerError(t *testing.T) {
+	serveHosts(t, func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "oops", http.StatusInternalServerError)
+	})
+
+	_, _, err := fetchRepoLicense("https://github.com/user/foo", "master")
+	if err == nil {
+		t.Fatal("expected error for server error")
+	}
+
+	_, _, err = fetchRepoLicense("https://example.com/user/foo", "master")
+	if err == nil {
+		t.Fatal("expected error for unsupported host")
+	}
+}
+
+func TestGetRawFileURL(t *testing.T) {
+	tests := []struct {
+		repoURL  string
+		expected string
+	}{
+		{
+			"https://github.com/user/foo.git",
+			"https://raw.githubusercontent.com/user/foo/v1/LICENSE",
+		},
+		{
+			"https://gitlab.com/group/foo",
+			"https://gitlab.com/group/foo/-/raw/v1/LICENSE",
+		},
+		{
+			"https://git.sr.ht/~user/foo",
+			"https://git.sr.ht/~user/foo/blob/v1/LICENSE",
+		},
+		{"https://example.com/user/foo", ""},
+	}
+
+	for _, test := range tests {
+		fileURL := getRawFileURL(test.repoURL, "v1", "LICENSE")
+		if fileURL != test.expected {
+			t.Errorf(
+				"%s: expected %q, got %q", test.repoURL, test.expected, fileURL,
+			)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type lintWarning struct {
+	Field   string
+	Message string
+}
+
+func (warning lintWarning) String() string {
+	return warning.Field + ": " + warning.Message
+}
+
+// lintServiceBinaries returns warnings about services which start binaries
+// not listed among installed ones.
+func lintServiceBinaries(services []serviceData, binaries []string) []string {
+	installed := strings.Join(binaries, ", ")
+	if installed == "" {
+		installed = "none"
+	}
+
+	warnings := []string{}
+	for _, service := range services {
+		if isStringInList(service.ExecName, binaries) {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf(
+			"service %s starts /%s/%s, which is not installed "+
+				"by package, installed binaries: %s",
+			service.UnitName, service.BinDir, service.ExecName, installed,
+		))
+	}
+
+	return warnings
+}
+
+// lintPkgData checks package data for common mistakes, which makepkg either
+// rejects or silently accepts producing broken package.
+func lintPkgData(data pkgData) []lintWarning {
+	warnings := []lintWarning{}
+
+	hasPkgVerFunc := !data.IsMeta && !data.IsBinRelease
+	switch {
+	case data.PkgVer == "" && !hasPkgVerFunc:
+		warnings = append(warnings, lintWarning{"pkgver", "is not set"})
+
+	case data.IsPkgVerPlaceholder:
+		warnings = append(warnings, lintWarning{"pkgver", "is placeholder"})
+	}
+
+	if strings.TrimSpace(data.PkgDesc) == "" {
+		warnings = append(warnings, lintWarning{"pkgdesc", "is empty"})
+	}
+
+	if hasUnescapedShellChars(data.PkgDesc) {
+		warnings = append(warnings, lintWarning{
+			"pkgdesc", "contains unescaped quotes, dollar signs or backticks",
+		})
+	}
+
+	if len(data.Licenses) == 0 ||
+		len(data.Licenses) == 1 && strings.TrimSpace(data.Licenses[0]) == "" {
+		warnings = append(warnings, lintWarning{"license", "is not set"})
+	}
+
+	arrays := []struct {
+		Field   string
+		Entries []string
+	}{
+		{"license", data.Licenses},
+		{"depends", data.Dependencies},
+		{"makedepends", data.MakeDependencies},
+		{"provides", data.Provides},
+		{"conflicts", data.Conflicts},
+		{"backup", data.Backup},
+	}
+
+	for _, array := range arrays {
+		for _, entry := range array.Entries {
+			if strings.ContainsAny(entry, " \t\n'\"") {
+				warnings = append(warnings, lintWarning{
+					array.Field,
+					fmt.Sprintf("entry %q contains whitespace or quotes", entry),
+				})
+			}
+		}
+	}
+
+	return warnings
+}
+
+// hasUnescapedShellChars checks if value contains double quotes, dollar signs
+// or backticks not escaped by backslash, which break or expand value placed
+// between double quotes in PKGBUILD.
+func hasUnescapedShellChars(value string) bool {
+	isEscaped := false
+	for _, char := range value {
+		switch {
+		case isEscaped:
+			isEscaped = false
+
+		case char == '\\':
+			isEscaped = true
+
+		case strings.ContainsRune("\"$`", char):
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintPkgDataWithCleanData(t *testing.T) {
+	warnings := lintPkgData(pkgData{
+		PkgName:      "foo",
+		PkgDesc:      "does things",
+		Licenses:     []string{"MIT"},
+		Dependencies: []string{"glibc"},
+	})
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %q", warnings)
+	}
+}
+
+func TestLintPkgDataRules(t *testing.T) {
+	clean := func() pkgData {
+		return pkgData{
+			PkgName:  "foo",
+			PkgDesc:  "does things",
+			Licenses: []string{"MIT"},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(data *pkgData)
+		expected []lintWarning
+	}{
+		{
+			"missing pkgver",
+			func(data *pkgData) { data.IsMeta = true },
+			[]lintWarning{{"pkgver", "is not set"}},
+		},
+		{
+			"placeholder pkgver",
+			func(data *pkgData) {
+				data.PkgVer = "1"
+				data.IsPkgVerPlaceholder = true
+			},
+			[]lintWarning{{"pkgver", "is placeholder"}},
+		},
+		{
+			"empty pkgdesc",
+			func(data *pkgData) { data.PkgDesc = " " },
+			[]lintWarning{{"pkgdesc", "is empty"}},
+		},
+		{
+			"pkgdesc with shell characters",
+			func(data *pkgData) { data.PkgDesc = "runs `cmd` in $HOME" },
+			[]lintWarning{{
+				"pkgdesc",
+				"contains unescaped quotes, dollar signs or backticks",
+			}},
+		},
+		{
+			"pkgdesc with escaped shell characters",
+			func(data *pkgData) {
+				data.PkgDesc = `runs \"cmd\" in \$HOME, not \\`
+			},
+			[]lintWarning{},
+		},
+		{
+			"no license",
+			func(data *pkgData) { data.Licenses = nil },
+			[]lintWarning{{"license", "is not set"}},
+		},
+		{
+			"entry with spaces",
+			func(data *pkgData) {
+				data.Dependencies = []string{"glibc", "foo bar"}
+			},
+			[]lintWarning{{
+				"depends", `entry "foo bar" contains whitespace or quotes`,
+			}},
+		},
+		{
+			"entry with quotes",
+			func(data *pkgData) { data.Backup = []string{`etc/"foo"`} },
+			[]lintWarning{{
+				"backup", `entry "etc/\"foo\"" contains whitespace or quotes`,
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		data := clean()
+		test.modify(&data)
+
+		warnings := lintPkgData(data)
+		if !reflect.DeepEqual(warnings, test.expected) {
+			t.Errorf(
+				"%s: expected %q, got %q", test.name, test.expected, warnings,
+			)
+		}
+	}
+}
+
+func TestLintServiceBinaries(t *testing.T) {
+	binaries := getInstalledBinaries(
+		[]pkgFile{
+			{Path: "usr/bin/foo-helper"},
+			{Path: "etc/foo/foo.conf"},
+		},
+		"usr/bin", "foo-server",
+	)
+
+	expected := []string{"foo-helper", "foo-server"}
+	if !reflect.DeepEqual(binaries, expected) {
+		t.Fatalf("expected %q, got %q", expected, binaries)
+	}
+
+	tests := []struct {
+		execName  string
+		isWarning bool
+	}{
+		{"foo-server", false},
+		{"foo-helper", false},
+		{"foo", true},
+	}
+
+	for _, test := range tests {
+		warnings := lintServiceBinaries([]serviceData{{
+			UnitName: "foo",
+			BinDir:   "usr/bin",
+			ExecName: test.execName,
+		}}, binaries)
+
+		switch {
+		case !test.isWarning && len(warnings) != 0:
+			t.Errorf("%s: expected no warnings, got %q", test.execName, warnings)
+
+		case test.isWarning && (len(warnings) != 1 ||
+			!strings.Contains(warnings[0], "/usr/bin/foo, which is not")):
+			t.Errorf("%s: expected warning, got %q", test.execName, warnings)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"crypto/md5"
 	"fmt"
 	"io"
@@ -11,7 +12,13 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
+	"time"
 
 	"github.com/docopt/docopt-go"
 )
@@ -31,6 +38,14 @@ installation.
 E.g., if you want to include config to the package, place it into
 'etc/somename/config.conf' directory.
 
+Defaults for maintainer, license, depends and makedepends can be set in the
+user configuration file '$XDG_CONFIG_HOME/go-makepkg/config.toml' and
+overridden by the project configuration file '.go-makepkg.toml' in the current
+directory, e.g.:
+  maintainer = "John Doe <john@example.com>"
+  license = "MIT"
+  makedepends = ["gcc"]
+
 'go-makepkg' will store generated build, service and additional files in the
 'build' directory (by default).
 
@@ -38,60 +53,520 @@ Trivial run is (all files in the directory except generated will be included
 into the package):
   go-makepkg "my cool package" git://my-repo-url **/* -B
 
+Repo can be specified using shorthand 'github:<user>/<repo>',
+'gitlab:<user>/<repo>' or 'sr.ht:~<user>/<repo>'. Repo also can be specified
+as Go import path, like 'gopkg.in/yaml.v2', then repo URL is resolved using
+go-import meta tag and source is placed under that import path in GOPATH.
+
 Note: if you want to create package for the project, which uses sub-directories
 for binaries and go-gettable with suffix '...', you should specify that suffix
 to repo URL as well, like:
   go-makepkg "gb tool" git://github.com/constabulary/gb/... -B
 
 Usage:
-  go-makepkg [options] <desc> <repo> [<file>...]
+  go-makepkg [options] [--source <URL>]... [--source-arch <SOURCE>]...
+             [--source-noextract <FILENAME>]... [--source-skip <NAME>]...
+             [--patch <FILE>]...
+             [--var <VAR>]... [--backup <PATH>]... [--no-backup <PATH>]...
+             [--license-file <FILE>]... [--tree <TREE>]...
+             [--svc-for <BINARY>]... [--dlagent <AGENT>]...
+             [--chown <OWNERSHIP>]... [--svc-override <SETTING>]...
+             [--completion-from-binary <SHELL>]... [--arch-map <MAPPING>]...
+             [--co-maintainer <NAME>]... [--pgp-key <FINGERPRINT>]...
+             [--mode <FILEMODE>]... [--doc <FILE>]... [--mkdir <DIR>]...
+             (--desc-from-godoc <DIR> | --desc-from-changelog <FILE> | <desc>)
+             <repo> [<file>...]
   go-makepkg -h | --help
   go-makepkg -v | --version
 
 Options:
   -v --version  Show version.
   -h --help     Show this help.
+  -q --quiet    Do not print progress messages, only warnings and errors.
+  --no-color    Do not color output. Output is also not colored when it's
+                not a terminal or NO_COLOR environment variable is set.
+  --force-color  Color output even if it's not a terminal.
   -s            Create service file and include it to the package.
   -g            Create .gitignore file.
   -B            Run 'makepkg' after creating PKGBUILD.
+  --auto-depends-elf  After build with -B, inspect shared libraries listed
+                in NEEDED entries of built binaries and print packages owning
+                them as suggested depends. Doesn't work with -c and chroot
+                builds.
+  --auto-depends  Same as --auto-depends-elf.
+  --write-depends  Add depends suggested by --auto-depends-elf to PKGBUILD
+                instead of only printing them. Package should be rebuilt
+                afterwards to include them.
   -c            Clean up leftover files and folders.
   -n <PKGNAME>  Use specified package name instead of automatically generated
                 from <repo> URL.
-  -l <LICENSE>  License to use [default: GPL].
-  -r <PKGREL>   Specify package release number [default: 1].
+  --pkgbase <NAME>  Set pkgbase, if it differs from package name.
+  -l <LICENSE>  Comma-separated list of licenses to use, 'none' or
+                'unknown' can be used for code without license$LICENSE.
+  --pkgver <VERSION>  Use specified static package version instead of the one
+                generated from repo by pkgver().
+  --pkgver-cmd <CMD>  Run specified shell command and use its output as
+                static package version, like 'cat VERSION'. Leading 'v' is
+                removed and dashes are replaced with dots.
+  -r <PKGREL>   Specify package release number, like '1' or '1.1' for
+                rebuilds [default: 1].
   -d <DIR>      Directory to place PKGBUILD [default: build].
+  --dir-mode <MODE>  Octal permissions of created directory to place
+                PKGBUILD [default: 0755].
   -o <NAME>     File to write PKGBUILD [default: PKGBUILD].
   -m <NAME>     Specify maintainer$MAINTAINER.
+  --co-maintainer <NAME>  Add co-maintainer, listed after maintainer in
+                PKGBUILD header. Can be specified multiple times.
   -p <VAR>      Pass pkgver to specified global variable using ldflags.
-  -D <LIST>     Comma-separated list of runtime package dependencies (depends).
-  -M <LIST>     Comma-separated list of make package dependencies (makedepends).
+  -D <LIST>     Comma-separated list of runtime package dependencies
+                (depends)$DEPENDS.
+  -M <LIST>     Comma-separated list of make package dependencies
+                (makedepends)$MAKEDEPENDS.
+  --provides <LIST>  Comma-separated list of virtual packages provided by
+                package (provides).
+  --conflicts <LIST>  Comma-separated list of packages conflicting with
+                package (conflicts).
+  --no-auto-conflicts  Do not add package name without '-git' or '-bin'
+                suffix to provides and conflicts of variant package.
+  --depends-file <FILE>  Read runtime package dependencies from specified
+                file, one per line. Text after '#' is ignored.
+  --makedepends-file <FILE>  Read make package dependencies from specified
+                file, one per line. Text after '#' is ignored.
+  --source <URL>  Add remote source, optionally in form <NAME>::<URL>.
+                Sources are downloaded into output directory to compute their
+                sums. Can be specified multiple times.
+  --dlagent <AGENT>  Add download agent in form <PROTO>::<COMMAND> to
+                DLAGENTS for sources with custom protocol, like
+                's3::/usr/bin/aws s3 cp %u %o'. Can be specified multiple
+                times.
+  --pgp-key <FINGERPRINT>  Add fingerprint of key used to sign sources to
+                validpgpkeys. Can be specified multiple times.
+  --import-pgp-keys  Import keys specified by --pgp-key from keyserver
+                using 'gpg --recv-keys' before running build with -B.
+  --skip-remote-sums  Do not download remote sources, use 'SKIP' for their
+                sums instead.
+  --source-rename <NAME>  Use specified name for directory of repo
+                checkout instead of '$_pkgname', like 'NAME::git+URL'.
+  --goarch <LIST>  Comma-separated list of GOARCH values, like 'amd64,arm64',
+                to derive arch list from instead of default 'i686' and
+                'x86_64'.
+  --arch-map <MAPPING>  Add or override mapping of GOARCH value to
+                architecture used by --goarch in form <GOARCH>=<ARCH>, like
+                'arm=armv7h'. Can be specified multiple times.
+  --source-arch <SOURCE>  Add architecture-specific source in form
+                <ARCH>:<URL>. Architecture is added to the arch list if
+                missing. Can be specified multiple times.
+  --source-skip <NAME>  Use 'SKIP' for sum of source with specified file
+                name even if it can be downloaded, which is useful for
+                frequently changing URLs. Can be specified multiple times.
+  --source-noextract <FILENAME>  Do not extract source with specified file
+                name. Can be specified multiple times.
+  --patch <FILE>  Include patch as source and apply it in prepare().
+                Patches are applied in specified order. Can be specified
+                multiple times.
+  --patch-strip <N>  Strip level passed to 'patch -p' [default: 1].
+  --list-go-deps <GOMOD>  List modules required by specified go.mod file
+                as comments in PKGBUILD for reference. They are not added to
+                depends.
+  --deps-from-cgo <DIR>  Scan local source checkout in specified directory
+                for '#cgo pkg-config:' directives and add packages providing
+                found libraries to depends. Mapping is approximate.
+  --debug-package  Build binaries without optimizations and keep debug
+                symbols by setting options=(debug !strip).
+  --split-debug  Build binaries with debug symbols and let makepkg strip
+                them into '<pkgbase>-debug' companion package by setting
+                options=(debug strip). DWARF compression of Go linker is
+                disabled, since makepkg can't extract compressed symbols.
+  --var <VAR>   Declare variable in form <NAME>=<VALUE> in PKGBUILD. Value
+                is written as is, so quote it if needed. Can be specified
+                multiple times.
+  --mode <FILEMODE>  Install included file with specified mode in form
+                <PATH>:<OCTAL>, like 'etc/app/secret.conf:0600'. Can be
+                specified multiple times.
+  --mkdir <DIR>  Create empty directory in package in form <PATH>:<OCTAL>,
+                like 'var/log/app:0750'. Mode can be omitted, then 0755 is
+                used. Can be specified multiple times.
+  --backup <PATH>  Add specified path to backup in addition to files under
+                'etc/'. Can be specified multiple times.
+  --no-backup <PATH>  Exclude specified path from backup. Path can be glob
+                pattern, like 'etc/app/cache/*', matched against install
+                paths. Can be specified multiple times.
+  --svc-name <NAME>  Use specified systemd unit name instead of package
+                name.
+  --svc-socket <LISTEN>  Create '<unit>.socket' companion unit listening on
+                specified address or socket path.
+  --svc-timer <CALENDAR>  Create '<unit>.timer' companion unit, which runs
+                service on specified OnCalendar schedule, like 'daily'.
+  --svc-for <BINARY>  Create separate service file named after specified
+                binary instead of single service for package, useful when
+                package ships several daemons. Can be specified multiple
+                times.
+  --svc-output <NAME>  Write service file to specified file name in
+                output directory instead of '<unit>.service'. Service is
+                still installed under unit name.
+  --svc-dropin <UNIT>  Create drop-in for specified existing unit, like
+                'foo.service', and install it as '<UNIT>.d/override.conf'
+                instead of full unit.
+  --svc-override <SETTING>  Add setting in form <KEY>=<VALUE> to [Service]
+                section of drop-in. Can be specified multiple times.
+  --svc-user-unit  Install service as systemd user unit.
+  --detect-license  Detect license by contents of license files specified
+                by --license-file, or LICENSE or COPYING file found among
+                specified files or in the current directory. Unrecognized
+                license is set to 'custom'.
+  --fetch-license  Download LICENSE or COPYING file of the repo from repo
+                host, which is supported for GitHub, GitLab and sourcehut, and
+                install it like --license-file. License is detected from it
+                with --detect-license.
+  --license-file <FILE>  Install specified license file to
+                license directory. Can be specified multiple times.
+  --doc <FILE>  Install specified documentation file, like README.md, to
+                'usr/share/doc/<pkgname>'. Can be specified multiple times.
+  --gzip-doc    Compress documentation files larger than 4 KiB with gzip.
+  --license-install-dir <DIR>  Directory to install license files to,
+                '<pkgname>' is replaced with package name
+                [default: usr/share/licenses/<pkgname>].
+  --ref <REF>   Build from specified git ref instead of the branch from
+                $BRANCH env variable (default branch of the repo by default).
+                Ref should be specified as branch=<BRANCH>, tag=<TAG> or
+                commit=<COMMIT>.
+  --shallow     Pin source to commit, which branch currently points to,
+                since makepkg can't make shallow clones of git sources. Ref
+                of tag or commit kind is used as is. Limitation is explained
+                in PKGBUILD comment.
+  --pkgver-sed <EXPR>  Sed expression used in pkgver() to convert output
+                of 'git describe' to version when building from tag
+                [default: s/^v//;s/-/./g].
+  --default-branch <NAME>  Use specified default branch instead of 'master'
+                when no --ref is given.
+  --detect-default-branch  Query remote repo for its default branch instead
+                of using 'master'. Queried branch is cached for a day in
+                '$XDG_CACHE_HOME/go-makepkg/default-branches'.
+  --chroot      Build package in clean chroot using 'extra-x86_64-build'
+                instead of 'makepkg'.
+  --chroot-dir <DIR>  Build package in clean chroot located in specified
+                directory using 'makechrootpkg'.
+  --config-template <FILE>  Include specified file to the package as
+                'etc/<pkgname>/config.toml' and add it to backup.
+  --profile-d <FILE>  Include specified shell script to the package as
+                'etc/profile.d/<name>.sh' and add it to backup. Extension
+                '.sh' is added if missing, since only such scripts are sourced.
+  --install-script  Create install scriptlet. If service file is created,
+                scriptlet stops and disables it on package removal.
+  --binary-name <NAME>  Install built binary under specified name instead
+                of its own and use it in the service file.
+  --prefix <DIR>  Install binaries into '<DIR>/bin' inside the package
+                [default: usr].
+  --meta        Create metapackage, which only pulls dependencies and
+                contains no binaries. <repo> is used only to derive package
+                name.
+  --bin-release <URL>  Create '-bin' package, which installs pre-built
+                binary downloaded from specified URL instead of building it.
+                If URL points to archive, binary named after package is
+                expected at its top level. <repo> is used only to derive
+                package name.
+  --respect-makeflags  Take number of build jobs from '-jN' of MAKEFLAGS
+                found in environment or makepkg.conf, unless it's set with
+                --build-jobs.
+  --use-make    Build using 'make' with Makefile of the repo instead of
+                'go get' and install using 'make install' with DESTDIR set to
+                package directory.
+  --go-install  Install binaries in package() using 'go install' with GOBIN
+                set to package directory instead of building them in build()
+                and copying.
+  --make-target <TARGET>  Build specified make target instead of default
+                one with --use-make.
+  --build-jobs <N>  Limit build parallelism to specified number of jobs by
+                setting GOMAXPROCS and passing '-p' to go.
+  --goproxy <URL>  Set GOPROXY for the build.
+  --gosumdb <VALUE>  Set GOSUMDB for the build, use 'off' to disable
+                checksum database.
+  --timeout <DURATION>  Kill external commands and abort downloads which
+                take longer than specified duration, like '30s' or '1h'. By
+                default build is not limited, while repo checks are limited
+                to 30s.
+  --makepkg-conf <FILE>  Pass specified config file to 'makepkg' when
+                running build.
+  --strict      Fail if generated PKGBUILD has lint warnings, like empty
+                description or unset license.
+  --refresh-files  Replace files already existing in output directory with
+                included ones even if their contents are the same.
+  --force       Overwrite PKGBUILD, service file and .gitignore even if they
+                were not generated by go-makepkg.
+  --skip-existing  Do not write PKGBUILD, service file and .gitignore if
+                they already exist, which is useful when they are maintained
+                by hand. Existing service file is still included to the
+                package.
+  --diff        Show difference between existing and newly generated
+                PKGBUILD before overwriting it.
+  --dry-run     Do not write any files and do not run build. Generated
+                PKGBUILD is printed unless --diff is specified. With -B, build
+                command is printed instead of running it.
+  --tree <TREE>  Include directory tree to the package in form
+                <SRC>:<DESTPREFIX>. Every file under <SRC> is installed under
+                <DESTPREFIX> preserving relative path. Can be specified
+                multiple times.
+  --desc-max-length <N>  Warn if description is longer than specified
+                number of characters [default: 80].
+  --desc-from-godoc <DIR>  Use first sentence of main package doc comment
+                found in local checkout in specified directory as
+                description instead of <desc>. Description defaults to
+                'Go program <name>' if there is no doc comment.
+  --desc-from-changelog <FILE>  Use first meaningful line of specified
+                changelog file as description instead of <desc>. Titles,
+                version headers and markdown markup are skipped.
+  --desc-suffix <TEXT>  Append specified text to description, like
+                '(git version)', which is appended by default when pkgver is
+                generated from git commits. Use empty text to disable it.
+  --fix-desc    Fix description which is too long, starts with package name
+                or ends with period.
+  --reproducible  Set SOURCE_DATE_EPOCH to the last commit time and build
+                with -trimpath for reproducible builds.
+  --prepend-pkgbuild <FILE>  Insert contents of specified file at the
+                beginning of PKGBUILD.
+  --append-pkgbuild <FILE>  Insert contents of specified file at the end of
+                PKGBUILD.
+  --import-path <PATH>  Place source under specified import path in GOPATH
+                instead of the one derived from repo URL, which is required
+                for projects with vanity import path.
+  --gopath-import <PATH>  Place source under specified directory of GOPATH
+                source tree, like 'example.com/user/repo', instead of the one
+                derived from repo URL, for hosts which URL layout doesn't
+                match import path.
+  --cmd-dir <PATH>  Build main package located in specified directory of
+                the repo, like 'cmd/tool', instead of the repo root.
+  --main-file <FILE>  Build single Go file of the repo, like 'tool.go',
+                instead of package, which is useful for programs consisting
+                of one file. Repo without go.mod is built in GOPATH mode.
+  --workspace-module <PATH>  Build module located in specified directory of
+                go.work workspace, like 'tools', in workspace mode. Command
+                directory set with --cmd-dir is relative to that module.
+  --single-binary <DIR>  Fail if local source checkout in specified
+                directory contains several main packages, unless build is
+                wildcard or command directory is set with --cmd-dir.
+  --apparmor    Create AppArmor profile stub for the binary, include it to
+                the package as 'etc/apparmor.d/<pkgname>' and reload it
+                using install scriptlet.
+  --chown <OWNERSHIP>  Change owner of installed path in form
+                <PATH>:<USER>:<GROUP> using install scriptlet, because package
+                files can be owned only by root. User and group should exist
+                at installation time. Can be specified multiple times.
+  --enable-service  Enable created service on package installation using
+                install scriptlet. Implies --install-script.
+  --check-repo  Check that remote repo exists and is accessible before
+                generating PKGBUILD.
+  --check-aur   Check that package with the same name is not published in
+                AUR yet.
+  --gen-man     Generate basic man page from output of running binary with
+                '--help' and include it to the package under 'man1'. Binary
+                is taken from previous build with -B, so run tool again after
+                build to include man page.
+  --help-text <FILE>  Generate man page from help text stored in specified
+                file instead of running binary. Implies --gen-man.
+  --completion-from-binary <SHELL>  Include completion script for
+                specified shell, one of bash, fish or zsh, printed by
+                'completion <SHELL>' subcommand of binary, which is common
+                for cobra-based programs. Binary is taken from previous build
+                with -B, like for --gen-man. Can be specified multiple times.
+  --completion-hook  Create pacman hook which rebuilds zsh completion dump,
+                if package includes zsh completions.
+  --archive <FILE>  Pack PKGBUILD and files included as sources, along with
+                .SRCINFO and .gitignore if present, into specified .tar.gz
+                file after generation.
+  --pre-gen-hook <CMD>  Run specified shell command before generating
+                files. Package name and output directory are passed in
+                GO_MAKEPKG_PKGNAME and GO_MAKEPKG_DIR environment variables.
+                Failed hook aborts run. Hooks are not run with --dry-run.
+  --post-gen-hook <CMD>  Run specified shell command after generating files.
+  --pre-build-hook <CMD>  Run specified shell command before build with -B.
+  --post-build-hook <CMD>  Run specified shell command after successful
+                build with -B.
+  --report      Print summary of generated package to stderr.
+  --watch       Watch included files and output directory and regenerate
+                PKGBUILD on their change until interrupted.
+  --print-config  Print resolved options, including defaults from
+                configuration files, as JSON and exit.
+  --template-dir <DIR>  Directory with templates overriding built-in ones.
+                Recognized names are PKGBUILD.tmpl, service.tmpl,
+                socket.tmpl, timer.tmpl, gitignore.tmpl, install.tmpl,
+                apparmor.tmpl, dropin.tmpl and manpage.tmpl; missing ones
+                fall back to built-ins.
 `
 
+const generatedMarker = "# Generated by go-makepkg"
+
+const zshCompletionDir = "usr/share/zsh/site-functions"
+
+var isQuiet bool
+
+var pkgVerRegexp = regexp.MustCompile(`^[A-Za-z0-9._+]+$`)
+
+var pkgRelRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+var userNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$`)
+
+var shellIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pkgFile is packaged file: it's placed into output directory, listed in
+// source array along with its sum and installed to the package. Files which
+// are only needed to build package, like PKGBUILD itself, are never
+// represented by pkgFile, see getBuildDirFiles.
 type pkgFile struct {
-	Path string
-	Name string
-	Hash string
+	Source    string
+	Path      string
+	Name      string
+	Hash      string
+	Mode      string
+	NoExtract bool
+}
+
+type pkgSource struct {
+	URL       string
+	Hash      string
+	NoExtract bool
+	SkipSum   bool
+}
+
+type pkgArchSources struct {
+	Arch    string
+	Sources []pkgSource
+}
+
+type pkgVar struct {
+	Name  string
+	Value string
 }
 
 type pkgData struct {
 	Maintainer       string
+	CoMaintainers    []string
+	UseMake          bool
+	IsGoInstall      bool
+	MainFile         string
+	WorkspaceModule  string
+	GoFlags          string
+	Dirs             []pkgDir
+	ValidPGPKeys     []string
+	MakeTarget       string
+	PkgBase          string
 	PkgName          string
+	PkgVer           string
+	PkgVerSed        string
 	PkgRel           string
 	PkgDesc          string
 	ProgramName      string
 	RepoURL          string
-	License          string
+	SourceDir        string
+	GoSrcDir         string
+	RefKind          string
+	RefName          string
+	IsShallow        bool
+	DefaultBranch    string
+	Licenses         []string
+	Arch             []string
 	Files            []pkgFile
+	Sources          []pkgSource
+	ArchSources      []pkgArchSources
+	NoExtract        []string
+	Patches          []pkgFile
+	PatchStrip       string
+	IsDebugPackage   bool
+	IsSplitDebug     bool
+	InstallScript    string
+	ExtraVars        []pkgVar
+	GoDependencies   []string
+	DLAgents         []string
 	Dependencies     []string
 	MakeDependencies []string
+	Provides         []string
+	Conflicts        []string
 	Backup           []string
 	IsWildcardBuild  bool
 	VersionVarName   string
+	BinaryName       string
+	BuiltBinaryName  string
+	BinaryInstalls   []string
+	BinDir           string
+	CmdDir           string
+	IsMeta           bool
+	IsBinRelease     bool
+	BinReleaseFile   string
+	ExecName         string
+
+	IsPkgVerPlaceholder bool
+	GoProxy             string
+	GoSumDB             string
+	IsReproducible      bool
+	BuildJobs           string
 }
 
 type serviceData struct {
-	Description string
-	ExecName    string
+	UnitName     string
+	Description  string
+	ExecName     string
+	BinDir       string
+	WantedBy     string
+	ListenStream string
+	OnCalendar   string
+}
+
+// serviceUnit is single systemd unit file generated for service.
+type serviceUnit struct {
+	Kind     string
+	Name     string
+	Path     string
+	Template *template.Template
+}
+
+type buildOptions struct {
+	CleanUp     bool
+	Chroot      bool
+	ChrootDir   string
+	MakepkgConf string
+}
+
+type dropinData struct {
+	Overrides []pkgVar
+}
+
+type completionHookData struct {
+	CompletionDir string
+	DumpDir       string
+}
+
+type installData struct {
+	PkgName         string
+	Units           []string
+	EnableUnits     bool
+	IsUserUnits     bool
+	AppArmorProfile string
+	Chowns          []pkgChown
+}
+
+type pkgDir struct {
+	Path string
+	Mode string
+}
+
+type pkgChown struct {
+	Path  string
+	User  string
+	Group string
+}
+
+type apparmorData struct {
+	PkgName  string
+	ExecName string
+	BinDir   string
+}
+
+type gitignoreData struct {
+	PkgName string
+}
+
+type templateOverride struct {
+	Name     string
+	Template **template.Template
 }
 
 func parseCommaList(v interface{}) []string {
@@ -101,317 +576,3117 @@ func parseCommaList(v interface{}) []string {
 	return strings.Split(v.(string), ",")
 }
 
-func main() {
-	args, err := docopt.Parse(
-		replaceUsageDefaults(usage),
-		nil, true, "go-makepkg "+version, false, true,
-	)
+// readListFile reads list of values from specified file, one per line,
+// skipping blank lines and comments starting with '#'.
+func readListFile(name string) ([]string, error) {
+	contents, err := ioutil.ReadFile(name)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	var (
-		description       = args[`<desc>`].(string)
-		rawRepoURL        = args[`<repo>`].(string)
-		fileList          = args[`<file>`].([]string)
-		license           = args[`-l`].(string)
-		packageRelease    = args[`-r`].(string)
-		dirName           = args[`-d`].(string)
-		outputName        = args[`-o`].(string)
-		doRunBuild        = args[`-B`].(bool)
-		doCleanUp         = args[`-c`].(bool)
-		doCreateService   = args[`-s`].(bool)
-		doCreateGitignore = args[`-g`].(bool)
-		maintainer, _     = args[`-m`].(string)
-		versionVarName, _ = args[`-p`].(string)
-		dependencies      = parseCommaList(args[`-D`])
-		makeDependencies  = parseCommaList(args[`-M`])
-	)
+	values := []string{}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
 
-	safeRepoURL, isWildcardBuild := trimWildcardFromRepoURL(rawRepoURL)
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
 
-	repoURL, err := url.Parse(safeRepoURL)
-	if err != nil {
-		log.Fatal(err)
+		values = append(values, line)
 	}
 
-	if repoURL.Scheme == "ssh" || repoURL.Scheme == "ssh+git" {
-		safeRepoURL = strings.Replace(
-			safeRepoURL, repoURL.Scheme, "git+ssh", -1,
-		)
-	}
+	return values, nil
+}
 
-	// handle git@github.com:
-	if strings.Contains(repoURL.Host, ":") {
-		safeRepoURL = strings.Replace(
-			safeRepoURL,
-			repoURL.Host,
-			strings.Replace(repoURL.Host, ":", "/", -1),
-			-1,
-		)
+func getUniqueList(list []string) []string {
+	unique := []string{}
+	for _, value := range list {
+		if value != "" && !isStringInList(value, unique) {
+			unique = append(unique, value)
+		}
 	}
 
-	packageName := getPackageNameFromRepoURL(safeRepoURL)
-	if args[`-n`] != nil {
-		packageName = args[`-n`].(string)
-	}
+	return unique
+}
 
-	err = createOutputDir(dirName)
+func main() {
+	defaults, err := loadDefaults()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	files, err := prepareFileList(fileList, dirName)
+	args, err := docopt.Parse(
+		replaceUsageDefaults(usage, defaults),
+		nil, true, "go-makepkg "+version, false, true,
+	)
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
+	}
+
+	if args[`--print-config`].(bool) {
+		err = printConfig(os.Stdout, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
 	}
 
-	err = copyLocalFiles(files, dirName)
+	isQuiet = args[`--quiet`].(bool)
+
+	err = setupColor(
+		args[`--no-color`].(bool), args[`--force-color`].(bool),
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	backup := createBackupList(files)
-
-	if doCreateService {
-		serviceName := fmt.Sprintf("%s.service", packageName)
-		output, err := os.Create(filepath.Join(
-			dirName,
-			serviceName,
-		))
+	var (
+		description, _     = args[`<desc>`].(string)
+		rawRepoURL         = args[`<repo>`].(string)
+		fileList           = args[`<file>`].([]string)
+		licenses           = parseCommaList(args[`-l`])
+		packageRelease     = args[`-r`].(string)
+		dirName            = args[`-d`].(string)
+		outputName         = args[`-o`].(string)
+		doRunBuild         = args[`-B`].(bool)
+		doCleanUp          = args[`-c`].(bool)
+		doCreateService    = args[`-s`].(bool)
+		doCreateGitignore  = args[`-g`].(bool)
+		maintainer, _      = args[`-m`].(string)
+		coMaintainers      = args[`--co-maintainer`].([]string)
+		versionVarName, _  = args[`-p`].(string)
+		dependencies       = parseCommaList(args[`-D`])
+		makeDependencies   = parseCommaList(args[`-M`])
+		provides           = parseCommaList(args[`--provides`])
+		conflicts          = parseCommaList(args[`--conflicts`])
+		noAutoConflicts    = args[`--no-auto-conflicts`].(bool)
+		templateDir, _     = args[`--template-dir`].(string)
+		rawSources         = args[`--source`].([]string)
+		rawArchSources     = args[`--source-arch`].([]string)
+		goArchs            = parseCommaList(args[`--goarch`])
+		rawArchMap         = args[`--arch-map`].([]string)
+		rawPGPKeys         = args[`--pgp-key`].([]string)
+		doImportPGPKeys    = args[`--import-pgp-keys`].(bool)
+		noExtractNames     = args[`--source-noextract`].([]string)
+		skipSumNames       = args[`--source-skip`].([]string)
+		patchNames         = args[`--patch`].([]string)
+		patchStrip         = args[`--patch-strip`].(string)
+		cgoSourceDir, _    = args[`--deps-from-cgo`].(string)
+		doDebugPackage     = args[`--debug-package`].(bool)
+		doSplitDebug       = args[`--split-debug`].(bool)
+		rawExtraVars       = args[`--var`].([]string)
+		backupInclude      = args[`--backup`].([]string)
+		rawFileModes       = args[`--mode`].([]string)
+		backupExclude      = args[`--no-backup`].([]string)
+		svcSocket, _       = args[`--svc-socket`].(string)
+		svcTimer, _        = args[`--svc-timer`].(string)
+		doDetectLicense    = args[`--detect-license`].(bool)
+		doFetchLicense     = args[`--fetch-license`].(bool)
+		rawRef, _          = args[`--ref`].(string)
+		isShallow          = args[`--shallow`].(bool)
+		doBuildInChroot    = args[`--chroot`].(bool)
+		chrootDir, _       = args[`--chroot-dir`].(string)
+		configTemplate, _  = args[`--config-template`].(string)
+		profileScript, _   = args[`--profile-d`].(string)
+		doCreateInstall    = args[`--install-script`].(bool)
+		licenseFiles       = args[`--license-file`].([]string)
+		docFiles           = args[`--doc`].([]string)
+		doGzipDocs         = args[`--gzip-doc`].(bool)
+		binaryName, _      = args[`--binary-name`].(string)
+		rawPrefix          = args[`--prefix`].(string)
+		isMeta             = args[`--meta`].(bool)
+		binReleaseURL, _   = args[`--bin-release`].(string)
+		buildJobs, _       = args[`--build-jobs`].(string)
+		doUseMake          = args[`--use-make`].(bool)
+		doGoInstall        = args[`--go-install`].(bool)
+		makeTarget, _      = args[`--make-target`].(string)
+		serviceOutput, _   = args[`--svc-output`].(string)
+		doCheckAUR         = args[`--check-aur`].(bool)
+		rawDLAgents        = args[`--dlagent`].([]string)
+		singleBinaryDir, _ = args[`--single-binary`].(string)
+		doCreateAppArmor   = args[`--apparmor`].(bool)
+		archiveName, _     = args[`--archive`].(string)
+		doAutoDepends      = args[`--auto-depends`].(bool) ||
+			args[`--auto-depends-elf`].(bool)
+		doWriteDepends     = args[`--write-depends`].(bool)
+		importPath, _      = args[`--import-path`].(string)
+		gopathImport, _    = args[`--gopath-import`].(string)
+		goModName, _       = args[`--list-go-deps`].(string)
+		rawChowns          = args[`--chown`].([]string)
+		rawDirs            = args[`--mkdir`].([]string)
+		isStrict           = args[`--strict`].(bool)
+		pkgVerSed          = args[`--pkgver-sed`].(string)
+		doRefreshFiles     = args[`--refresh-files`].(bool)
+		dropinUnit, _      = args[`--svc-dropin`].(string)
+		rawOverrides       = args[`--svc-override`].([]string)
+		doForce            = args[`--force`].(bool)
+		doSkipExisting     = args[`--skip-existing`].(bool)
+		changelogName, _   = args[`--desc-from-changelog`].(string)
+		goProxy, _         = args[`--goproxy`].(string)
+		goSumDB, _         = args[`--gosumdb`].(string)
+		makepkgConf, _     = args[`--makepkg-conf`].(string)
+		rawTimeout, _      = args[`--timeout`].(string)
+		doShowDiff         = args[`--diff`].(bool)
+		isDryRun           = args[`--dry-run`].(bool)
+		trees              = args[`--tree`].([]string)
+		rawDescMaxLength   = args[`--desc-max-length`].(string)
+		doFixDescription   = args[`--fix-desc`].(bool)
+		rawDescSuffix      = args[`--desc-suffix`]
+		isReproducible     = args[`--reproducible`].(bool)
+		packageBase, _     = args[`--pkgbase`].(string)
+		defaultBranch, _   = args[`--default-branch`].(string)
+		doDetectBranch     = args[`--detect-default-branch`].(bool)
+		prependName, _     = args[`--prepend-pkgbuild`].(string)
+		appendName, _      = args[`--append-pkgbuild`].(string)
+		doSkipRemoteSums   = args[`--skip-remote-sums`].(bool)
+		rawDirMode         = args[`--dir-mode`].(string)
+		rawCmdDir, _       = args[`--cmd-dir`].(string)
+		rawMainFile, _     = args[`--main-file`].(string)
+		rawWorkspace, _    = args[`--workspace-module`].(string)
+		doEnableService    = args[`--enable-service`].(bool)
+		doCheckRepo        = args[`--check-repo`].(bool)
+		isUserUnit         = args[`--svc-user-unit`].(bool)
+		packageVersion, _  = args[`--pkgver`].(string)
+		licenseDir         = args[`--license-install-dir`].(string)
+		godocSourceDir, _  = args[`--desc-from-godoc`].(string)
+		serviceBinaries    = args[`--svc-for`].([]string)
+		dependsFile, _     = args[`--depends-file`].(string)
+		makeDependsFile, _ = args[`--makedepends-file`].(string)
+		sourceRename, _    = args[`--source-rename`].(string)
+		doWatch            = args[`--watch`].(bool)
+		doPrintReport      = args[`--report`].(bool)
+		doGenerateManPage  = args[`--gen-man`].(bool)
+		helpTextName, _    = args[`--help-text`].(string)
+		completionShells   = args[`--completion-from-binary`].([]string)
+
+		doCreateCompletionHook = args[`--completion-hook`].(bool)
+	)
 
+	if templateDir != "" {
+		err = loadTemplates(templateDir)
 		if err != nil {
 			log.Fatal(err)
 		}
+	}
+
+	_, err = strconv.ParseUint(patchStrip, 10, 0)
+	if err != nil {
+		log.Fatalf("invalid patch strip level: %q", patchStrip)
+	}
+
+	if args[`--respect-makeflags`].(bool) && buildJobs == "" {
+		jobs := parseMakeJobs(getMakeflags(makepkgConf))
+		if jobs > 0 {
+			logStep("Using %d build jobs from MAKEFLAGS...", jobs)
+
+			buildJobs = strconv.Itoa(jobs)
+		}
+	}
 
-		err = createServiceFile(output, serviceData{
-			Description: description,
-			ExecName:    packageName,
-		})
+	if buildJobs != "" && !isValidBuildJobs(buildJobs) {
+		log.Fatalf("invalid number of build jobs: %q", buildJobs)
+	}
+
+	if rawTimeout != "" {
+		commandTimeout, err = time.ParseDuration(rawTimeout)
+		if err != nil || commandTimeout <= 0 {
+			log.Fatalf("invalid timeout: %q", rawTimeout)
+		}
+	}
 
+	if makepkgConf != "" {
+		makepkgConf, err = filepath.Abs(makepkgConf)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		hash, err := getFileHash(output.Name())
+		_, err = os.Stat(makepkgConf)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		files = append(files, pkgFile{
-			Name: serviceName,
-			Path: filepath.Join(
-				"usr/lib/systemd/system/",
-				serviceName,
-			),
-			Hash: hash,
-		})
 	}
 
-	output, err := os.Create(filepath.Join(dirName, outputName))
+	pkgbuildPrepend, err := readSnippet(prependName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = createPkgbuild(output, pkgData{
-		Maintainer:       maintainer,
-		PkgName:          packageName,
-		PkgRel:           packageRelease,
-		ProgramName:      strings.TrimSuffix(packageName, "-git"),
-		RepoURL:          safeRepoURL,
-		License:          license,
-		PkgDesc:          description,
-		Files:            files,
-		Backup:           backup,
-		IsWildcardBuild:  isWildcardBuild,
-		VersionVarName:   versionVarName,
-		Dependencies:     dependencies,
-		MakeDependencies: makeDependencies,
-	})
+	pkgbuildAppend, err := readSnippet(appendName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if doCreateGitignore {
-		err = createGitignore(dirName, packageName)
+	if !isValidPkgRel(packageRelease) {
+		log.Fatalf("invalid package release: %q", packageRelease)
+	}
+
+	if pkgVerCommand, ok := args[`--pkgver-cmd`].(string); ok {
+		if packageVersion != "" {
+			log.Fatal("--pkgver and --pkgver-cmd can't be used together")
+		}
+
+		logStep("Running pkgver command...")
+
+		packageVersion, err = getCommandPkgVer(pkgVerCommand)
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		logSubStep("Using package version: %s", packageVersion)
+	}
+
+	if packageVersion != "" && !isValidPkgVer(packageVersion) {
+		log.Fatalf("invalid package version: %q", packageVersion)
+	}
+
+	isBinRelease := binReleaseURL != ""
+	if isBinRelease {
+		if isMeta {
+			log.Fatal("binary release package can't be metapackage")
+		}
+
+		if len(patchNames) > 0 {
+			log.Fatal("patches can't be applied to binary release")
+		}
+	}
+
+	if makeTarget != "" {
+		if !doUseMake {
+			log.Fatal("make target can't be set without --use-make")
+		}
+
+		if strings.ContainsAny(makeTarget, "'\"$`;&|<> ") {
+			log.Fatalf("invalid make target: %q", makeTarget)
+		}
+	}
+
+	if doUseMake && (isMeta || isBinRelease) {
+		log.Fatal("make can't be used for metapackage or binary release")
 	}
 
-	if doRunBuild {
-		err = runBuild(dirName, doCleanUp)
+	if doGoInstall && (isMeta || isBinRelease || doUseMake) {
+		log.Fatal(
+			"go install can't be used for metapackage, binary release " +
+				"or build using make",
+		)
+	}
+
+	packageVersion, isPkgVerPlaceholder := getPkgVer(
+		packageVersion, !isMeta && !isBinRelease,
+	)
+
+	if dependsFile != "" {
+		fileDependencies, err := readListFile(dependsFile)
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		dependencies = append(dependencies, fileDependencies...)
 	}
 
-	if doCleanUp {
-		err = cleanUp(dirName, packageName)
+	if makeDependsFile != "" {
+		fileDependencies, err := readListFile(makeDependsFile)
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		makeDependencies = append(makeDependencies, fileDependencies...)
 	}
-}
 
-func runBuild(dir string, cleanUp bool) error {
-	logStep("Running makepkg...")
+	dependencies = getUniqueList(dependencies)
+	makeDependencies = getUniqueList(makeDependencies)
 
-	args := []string{"-f"}
-	if cleanUp {
-		args = append(args, "-c")
+	refKind, refName, err := parseRef(rawRef)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	cmd := exec.Command("makepkg", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Dir = dir
+	if pkgVerSed == "" || strings.Contains(pkgVerSed, "'") {
+		log.Fatalf("invalid pkgver sed expression: %q", pkgVerSed)
+	}
 
-	err := cmd.Run()
+	pgpKeys, err := parsePGPKeys(rawPGPKeys)
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
 
-	return nil
-}
+	if doImportPGPKeys && len(pgpKeys) == 0 {
+		log.Fatal("no PGP keys to import, specify them with --pgp-key")
+	}
 
-func cleanUp(dir, pkgName string) error {
-	return os.RemoveAll(filepath.Join(dir, pkgName))
-}
+	extraVars, err := parseExtraVars(rawExtraVars)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func copyLocalFiles(files []pkgFile, outDir string) error {
-	logStep("Preparing local files...")
-	for _, file := range files {
-		logSubStep("Including file in the package: %s", file.Path)
+	safeRepoURL, isWildcardBuild := trimWildcardFromRepoURL(
+		convertScpLikeURL(expandRepoShorthand(rawRepoURL)),
+	)
+
+	if gopathImport != "" {
+		if importPath != "" {
+			log.Fatal("--import-path and --gopath-import can't be used together")
+		}
+
+		importPath = gopathImport
+	}
 
-		targetName := filepath.Join(outDir, file.Name)
+	if isGoImportPath(safeRepoURL) && !isMeta && !isBinRelease {
+		logStep("Resolving import path %s...", safeRepoURL)
 
-		_, err := os.Stat(targetName)
+		prefix, resolvedURL, err := resolveGoImportPath(
+			"https://", safeRepoURL,
+		)
 		if err != nil {
-			if !os.IsNotExist(err) {
-				return err
-			}
-		} else {
-			continue
+			log.Fatal(err)
 		}
 
-		err = os.Link(file.Path, targetName)
-		if err != nil {
-			return err
+		logSubStep("Using repo %s for import path %s", resolvedURL, prefix)
+
+		if safeRepoURL != prefix && rawCmdDir == "" && !isWildcardBuild {
+			rawCmdDir = strings.TrimPrefix(safeRepoURL, prefix+"/")
 		}
-	}
 
-	return nil
-}
+		if importPath == "" {
+			importPath = prefix
+		}
 
-func createOutputDir(dirName string) error {
-	if _, err := os.Stat(dirName); !os.IsNotExist(err) {
-		return err
+		safeRepoURL = resolvedURL
 	}
 
-	err := os.Mkdir(dirName, 0755)
+	repoURL, err := url.Parse(safeRepoURL)
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
 
-	return nil
-}
+	// source is prefixed with 'git+' in PKGBUILD, so 'ssh' scheme makes it
+	// 'git+ssh' understood by makepkg.
+	if repoURL.Scheme == "ssh+git" || repoURL.Scheme == "git+ssh" {
+		safeRepoURL = strings.Replace(safeRepoURL, repoURL.Scheme, "ssh", 1)
+	}
 
-func createPkgbuild(output io.Writer, data pkgData) error {
-	logStep("Creating PKGBUILD...")
-	return pkgbuildTemplate.Execute(output, data)
-}
+	// handle git@github.com:
+	if strings.Contains(repoURL.Host, ":") {
+		safeRepoURL = strings.Replace(
+			safeRepoURL,
+			repoURL.Host,
+			strings.Replace(repoURL.Host, ":", "/", -1),
+			-1,
+		)
+	}
 
-func createServiceFile(output io.Writer, data serviceData) error {
-	logStep("Creating service file...")
-	return serviceTemplate.Execute(output, data)
-}
+	if doCheckRepo && !isMeta && !isBinRelease {
+		if isLocalRepoURL(safeRepoURL) {
+			logStep("Skipping check of local repo...")
+		} else {
+			logStep("Checking repo...")
 
-func createGitignore(dirName string, pkgName string) error {
-	logStep("Creating .gitignore...")
+			err = checkRepo(safeRepoURL)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
 
-	ignoreFiles := []string{
-		"/*.tar.xz",
-		"/pkg",
-		"/src",
-		"/" + pkgName,
+	packageName := getPackageNameFromRepoURL(safeRepoURL)
+	if isBinRelease {
+		packageName += "-bin"
 	}
 
-	contents := strings.Join(ignoreFiles, "\n") + "\n"
+	if args[`-n`] != nil {
+		packageName = args[`-n`].(string)
+	}
 
-	return ioutil.WriteFile(
-		filepath.Join(dirName, ".gitignore"), []byte(contents), 0644,
+	programName := strings.TrimSuffix(
+		strings.TrimSuffix(packageName, "-git"), "-bin",
 	)
-}
 
-func prepareFileList(names []string, outDir string) ([]pkgFile, error) {
-	files := []pkgFile{}
+	if doCheckAUR {
+		logStep("Checking AUR for package name...")
 
-	for _, name := range names {
-		stat, err := os.Stat(name)
-		if os.IsExist(err) {
-			continue
+		exists, err := isAURPackageExists(aurRPCURL, packageName)
+		if err != nil {
+			logWarning("Can't check AUR, skipping: %s", err)
+		} else if exists {
+			logWarning(
+				"Package %s already exists in AUR, consider using "+
+					"%s-git or %s-bin name with -n",
+				packageName, programName, programName,
+			)
 		}
+	}
+
+	if defaultBranch == "" && !doDetectBranch {
+		defaultBranch = "master"
+	}
+
+	if refKind == "" && defaultBranch == "" && !isMeta && !isBinRelease {
+		logStep("Detecting default branch...")
 
+		defaultBranch, err = getRemoteDefaultBranch(safeRepoURL)
 		if err != nil {
-			return nil, err
+			logWarning(
+				"Can't detect default branch, using master; "+
+					"set it with --default-branch: %s",
+				err,
+			)
+			defaultBranch = "master"
+		} else {
+			logSubStep("Using default branch: %s", defaultBranch)
 		}
+	}
 
-		if stat.IsDir() {
-			continue
-		}
+	if isShallow && (isMeta || isBinRelease) {
+		log.Fatal("--shallow can't be used for metapackage or binary release")
+	}
 
-		if name == "PKGBUILD" {
-			continue
+	if isShallow {
+		refKind, refName = getPinnedRef(
+			safeRepoURL, refKind, refName, defaultBranch,
+		)
+	}
+
+	if cgoSourceDir != "" {
+		cgoDependencies, err := getCgoDependencies(cgoSourceDir)
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		if strings.HasPrefix(name, outDir) {
-			continue
+		for _, dependency := range cgoDependencies {
+			if !isStringInList(dependency, dependencies) {
+				dependencies = append(dependencies, dependency)
+			}
 		}
 
-		hash, err := getFileHash(name)
-		if err != nil {
-			return nil, err
+		if len(cgoDependencies) > 0 &&
+			!isStringInList("pkgconf", makeDependencies) {
+			makeDependencies = append(makeDependencies, "pkgconf")
 		}
+	}
 
-		files = append(files, pkgFile{
-			Path: name,
-			Name: path.Base(name),
-			Hash: hash,
-		})
+	for _, warning := range lintRelations(
+		dependencies, provides, conflicts,
+	) {
+		logWarning("Contradictory relations: %s", warning)
 	}
 
-	return files, nil
-}
+	// Variant package provides and conflicts with the base one, which is
+	// expected, so it's added after contradictions check.
+	provides, conflicts = addVariantRelations(
+		programName, packageName, provides, conflicts, noAutoConflicts,
+	)
 
-func getFileHash(path string) (string, error) {
-	hash := md5.New()
-	file, err := os.Open(path)
-	if err != nil {
-		return "", err
+	unlicensed := getUnlicensedLicense(licenses)
+	if unlicensed != "" {
+		licenses = []string{unlicensed}
+
+		if len(licenseFiles) > 0 {
+			log.Fatalf("license files can't be installed for license %q", unlicensed)
+		}
 	}
 
-	_, err = io.Copy(hash, file)
-	if err != nil {
-		return "", err
+	goDependencies := []string{}
+	if goModName != "" {
+		goDependencies, err = parseGoModRequires(goModName)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+	fetchedLicenseName := ""
+	fetchedLicense := []byte{}
+	if doFetchLicense && unlicensed == "" {
+		logStep("Fetching license from repo host...")
+
+		ref := refName
+		if refKind == "" {
+			ref = defaultBranch
+		}
+
+		fetchedLicenseName, fetchedLicense, err = fetchRepoLicense(
+			safeRepoURL, ref,
+		)
+		switch {
+		case err != nil:
+			logWarning("Can't fetch license, skipping: %s", err)
+
+		case fetchedLicenseName == "":
+			logWarning("No license file found in repo, skipping")
+
+		default:
+			logSubStep("Fetched license file: %s", fetchedLicenseName)
+		}
+	}
+
+	if doDetectLicense && unlicensed == "" {
+		if fetchedLicenseName != "" && len(licenseFiles) == 0 {
+			logStep("Detecting license...")
+
+			licenses = []string{matchLicense(string(fetchedLicense))}
+
+			logSubStep(
+				"Detected license in %s: %s", fetchedLicenseName, licenses[0],
+			)
+		} else {
+			licenses, err = detectLicenses(fileList, licenseFiles, licenses)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	isDescriptionRead := false
+
+	if godocSourceDir != "" {
+		logStep("Reading description from package doc comment...")
+
+		description, err = getGodocDescription(godocSourceDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if description == "" {
+			description = getDefaultDescription(programName)
+
+			logWarning(
+				"No doc comment in %q, using default description",
+				godocSourceDir,
+			)
+		} else {
+			isDescriptionRead = true
+		}
+
+		logSubStep("Using description: %s", description)
+	}
+
+	if changelogName != "" {
+		logStep("Reading description from changelog...")
+
+		description, err = getChangelogDescription(changelogName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		isDescriptionRead = true
+
+		logSubStep("Using description: %s", description)
+	}
+
+	descMaxLength, err := strconv.Atoi(rawDescMaxLength)
+	if err != nil || descMaxLength <= 0 {
+		log.Fatalf("invalid description max length: %q", rawDescMaxLength)
+	}
+
+	descSuffix := getDescriptionSuffix(
+		rawDescSuffix,
+		!isMeta && !isBinRelease && packageVersion == "" && refKind != "tag",
+	)
+
+	description = strings.TrimSpace(
+		strings.TrimSuffix(description, descSuffix),
+	)
+
+	descWarnings := lintDescription(
+		addDescriptionSuffix(description, descSuffix),
+		programName, descMaxLength,
+	)
+	if len(descWarnings) > 0 {
+		for _, warning := range descWarnings {
+			logWarning("Bad description: %s", warning)
+		}
+
+		if doFixDescription {
+			fixMaxLength := descMaxLength
+			if descSuffix != "" {
+				fixMaxLength -= len([]rune(descSuffix)) + 1
+			}
+
+			description = fixDescription(
+				description, programName, fixMaxLength,
+			)
+
+			logSubStep(
+				"Using fixed description: %s",
+				addDescriptionSuffix(description, descSuffix),
+			)
+		}
+	}
+
+	if isDescriptionRead {
+		description = escapeDescription(description)
+	}
+
+	description = addDescriptionSuffix(description, descSuffix)
+
+	sourceDir := "$_pkgname"
+	if sourceRename != "" {
+		if strings.ContainsAny(sourceRename, "/:$\"' ") {
+			log.Fatalf("invalid source name: %q", sourceRename)
+		}
+
+		sourceDir = sourceRename
+	}
+
+	builtBinaryName := sourceDir
+
+	if importPath == "" && !isMeta && !isBinRelease {
+		importPath = gopathImportDir(safeRepoURL)
+	}
+
+	goSrcDir := sourceDir
+	if importPath != "" {
+		importPath = path.Clean(importPath)
+		if path.IsAbs(importPath) || strings.HasPrefix(importPath, "..") ||
+			strings.ContainsAny(importPath, "$\"' ") {
+			log.Fatalf("invalid import path: %q", importPath)
+		}
+
+		goSrcDir = importPath
+		builtBinaryName = path.Base(importPath)
+	}
+
+	cmdDir := ""
+	if rawCmdDir != "" {
+		cmdDir = path.Clean(rawCmdDir)
+
+		if isWildcardBuild {
+			log.Fatal("command directory can't be set for wildcard build")
+		}
+
+		if path.IsAbs(rawCmdDir) || strings.HasPrefix(cmdDir, "..") {
+			log.Fatalf("command directory must be inside repo: %q", rawCmdDir)
+		}
+
+		builtBinaryName = path.Base(cmdDir)
+	}
+
+	mainFile := ""
+	if rawMainFile != "" {
+		mainFile = path.Clean(rawMainFile)
+
+		switch {
+		case isWildcardBuild || cmdDir != "":
+			log.Fatal(
+				"main file can't be set for wildcard build or with command " +
+					"directory",
+			)
+
+		case doUseMake || doGoInstall || isMeta || isBinRelease:
+			log.Fatal(
+				"main file can be set only for build using go build",
+			)
+
+		case path.IsAbs(mainFile) || strings.HasPrefix(mainFile, "..") ||
+			!strings.HasSuffix(mainFile, ".go") ||
+			strings.ContainsAny(mainFile, "$\"' "):
+			log.Fatalf("invalid main file: %q", rawMainFile)
+		}
+	}
+
+	workspaceModule, goFlags := "", ""
+	if rawWorkspace != "" {
+		workspaceModule = path.Clean(rawWorkspace)
+
+		switch {
+		case isWildcardBuild || mainFile != "":
+			log.Fatal(
+				"workspace module can't be set for wildcard build or with " +
+					"main file",
+			)
+
+		case doUseMake || doGoInstall || isMeta || isBinRelease:
+			log.Fatal(
+				"workspace module can be set only for build using go build",
+			)
+
+		case path.IsAbs(workspaceModule) ||
+			strings.HasPrefix(workspaceModule, "..") ||
+			strings.ContainsAny(workspaceModule, "$\"' "):
+			log.Fatalf("invalid workspace module: %q", rawWorkspace)
+		}
+
+		if cmdDir == "" && workspaceModule != "." {
+			builtBinaryName = path.Base(workspaceModule)
+		}
+
+		goFlags = goWorkFlags
+	}
+
+	if singleBinaryDir != "" && !isWildcardBuild {
+		logStep("Checking main packages...")
+
+		modules, err := getWorkspaceModules(singleBinaryDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch {
+		case workspaceModule != "" && !isStringInList(workspaceModule, modules):
+			log.Fatalf(
+				"module %q is not used by go.work workspace", workspaceModule,
+			)
+
+		case workspaceModule == "" && len(modules) > 0:
+			logWarning(
+				"Repo is go.work workspace of modules %s; "+
+					"use --workspace-module to select one",
+				strings.Join(modules, ", "),
+			)
+		}
+
+		mainDirs, err := findMainPackageDirs(
+			filepath.Join(singleBinaryDir, workspaceModule),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = checkSingleBinary(mainDirs, cmdDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	execName := packageName
+	if isBinRelease {
+		execName = programName
+	}
+
+	if binaryName != "" {
+		if isWildcardBuild {
+			log.Fatal("binary name can't be set for wildcard build")
+		}
+
+		if doUseMake || doGoInstall {
+			log.Fatal(
+				"binary name can't be set for build using make or go install",
+			)
+		}
+
+		execName = binaryName
+	}
+
+	prefix, err := parsePrefix(rawPrefix)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if prefix != defaultPrefix && (doUseMake || isMeta) {
+		log.Fatal("prefix can't be set for metapackage or build using make")
+	}
+
+	binDir := getBinDir(prefix)
+
+	unitName := packageName
+	if args[`--svc-name`] != nil {
+		unitName = strings.TrimSuffix(
+			args[`--svc-name`].(string), ".service",
+		)
+	}
+
+	if doSkipExisting && doForce {
+		log.Fatal("--skip-existing and --force can't be used together")
+	}
+
+	if doWriteDepends && !doAutoDepends {
+		log.Fatal("--write-depends can be used only with --auto-depends-elf")
+	}
+
+	if doSplitDebug && (doDebugPackage || isMeta || isBinRelease) {
+		log.Fatal(
+			"--split-debug can't be used with --debug-package, for " +
+				"metapackage or binary release",
+		)
+	}
+
+	if len(serviceBinaries) > 0 && !doCreateService {
+		log.Fatal("service binaries can't be set without creating service with -s")
+	}
+
+	if len(serviceBinaries) > 1 && args[`--svc-name`] != nil {
+		log.Fatal("service name can't be set for multiple service binaries")
+	}
+
+	if serviceOutput != "" {
+		if len(serviceBinaries) > 1 {
+			log.Fatal(
+				"service output can't be set for multiple service binaries",
+			)
+		}
+
+		if serviceOutput != filepath.Base(serviceOutput) {
+			log.Fatalf("invalid service output name: %q", serviceOutput)
+		}
+	}
+
+	if doEnableService && !doCreateService {
+		log.Fatal("service can't be enabled without creating it with -s")
+	}
+
+	if len(rawOverrides) > 0 && dropinUnit == "" {
+		log.Fatal("service overrides can't be set without --svc-dropin")
+	}
+
+	dirMode, err := parseFileMode(rawDirMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hooks := map[string]string{}
+	for _, stage := range []string{
+		"pre-gen", "post-gen", "pre-build", "post-build",
+	} {
+		if command, ok := args[`--`+stage+`-hook`].(string); ok {
+			hooks[stage] = command
+		}
+	}
+
+	runStageHook := func(stage string) {
+		if isDryRun {
+			if _, ok := hooks[stage]; ok {
+				logStep("Skipping %s hook in dry run...", stage)
+			}
+
+			return
+		}
+
+		err := runOptionalHook(hooks, stage, packageName, dirName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	runStageHook("pre-gen")
+
+	if !isDryRun {
+		err = createOutputDir(dirName, dirMode)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	pkgbuildPath := filepath.Join(dirName, outputName)
+
+	isPkgbuildSkipped := false
+	if !isDryRun {
+		isPkgbuildSkipped = isSkippedExisting(pkgbuildPath, doSkipExisting)
+	}
+
+	if !isDryRun && !isPkgbuildSkipped {
+		err = checkOverwrite(pkgbuildPath, doForce)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	files, err := prepareFileList(
+		getUniqueList(fileList), dirName,
+		getBuildDirFiles(outputName, packageName+".install"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	licenseDir, err = getLicenseDir(licenseDir, packageName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range licenseFiles {
+		licenseFile, err := prepareLicenseFile(name, licenseDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, licenseFile)
+	}
+
+	if fetchedLicenseName != "" {
+		name := filepath.Join(dirName, fetchedLicenseName)
+
+		hash, err := writeOutputFile(name, fetchedLicense, isDryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, pkgFile{
+			Source: name,
+			Path:   filepath.Join(licenseDir, fetchedLicenseName),
+			Name:   fetchedLicenseName,
+			Hash:   hash,
+		})
+	}
+
+	if isCustomLicense(licenses) && len(licenseFiles) == 0 &&
+		fetchedLicenseName == "" {
+		logWarning(
+			"Custom license requires license file, add it with --license-file",
+		)
+	}
+
+	for _, tree := range trees {
+		treeFiles, err := prepareTreeFileList(tree)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, treeFiles...)
+	}
+
+	if configTemplate != "" {
+		configFile, err := prepareConfigFile(configTemplate, packageName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, configFile)
+	}
+
+	if profileScript != "" {
+		profileFile, err := prepareProfileFile(profileScript)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, profileFile)
+	}
+
+	gzipDocFiles := []string{}
+	for _, name := range docFiles {
+		if doGzipDocs {
+			isCompressible, err := isDocCompressible(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if isCompressible {
+				gzipDocFiles = append(gzipDocFiles, name)
+				continue
+			}
+		}
+
+		docFile, err := prepareDocFile(name, packageName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, docFile)
+	}
+
+	// Files added after this point are generated, they are sorted by name
+	// before rendering, so PKGBUILD does not depend on generation order.
+	generatedFilesStart := len(files)
+
+	err = checkSourceNames(files)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !isDryRun {
+		err = copyLocalFiles(files, dirName, doRefreshFiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	patches, err := preparePatchList(patchNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(patches) > 0 && !isDryRun {
+		err = copyPatches(patches, dirName, doRefreshFiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, name := range gzipDocFiles {
+		logSubStep("Compressing documentation: %s", name)
+
+		docFile, err := createGzipDoc(name, dirName, packageName, isDryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, docFile)
+	}
+
+	apparmorProfile := ""
+	if doCreateAppArmor {
+		if isMeta || isWildcardBuild {
+			log.Fatal("AppArmor profile can be created only for single binary")
+		}
+
+		profile, err := prepareAppArmorProfile(
+			apparmorData{
+				PkgName:  packageName,
+				ExecName: execName,
+				BinDir:   binDir,
+			},
+			dirName, isDryRun,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		apparmorProfile = profile.Path
+
+		files = append(files, profile)
+	}
+
+	if doGenerateManPage || helpTextName != "" {
+		if isMeta || isWildcardBuild {
+			log.Fatal("man page can be generated only for single binary")
+		}
+
+		helpText := ""
+		if helpTextName != "" {
+			helpText, err = readHelpText(helpTextName)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			binary := getBuiltBinary(dirName, packageName, binDir, execName)
+			if binary == "" {
+				logWarning(
+					"No built binary %s found, skipping man page; "+
+						"run again after build with -B to include it",
+					execName,
+				)
+			} else {
+				logStep("Reading help text of %s...", execName)
+
+				helpText, err = getHelpText(binary)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+
+		if helpText != "" {
+			manPageName := execName + ".1"
+
+			contents := &bytes.Buffer{}
+			err = createManPage(
+				contents, parseHelpText(execName, description, helpText),
+			)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			hash, err := writeOutputFile(
+				filepath.Join(dirName, manPageName), contents.Bytes(), isDryRun,
+			)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			files = append(files, pkgFile{
+				Name: manPageName,
+				Path: path.Join("usr/share/man/man1", manPageName),
+				Hash: hash,
+				Mode: "0644",
+			})
+		}
+	}
+
+	if len(completionShells) > 0 {
+		if isMeta || isWildcardBuild {
+			log.Fatal("completion can be generated only for single binary")
+		}
+
+		binary := getBuiltBinary(dirName, packageName, binDir, execName)
+		if binary == "" {
+			logWarning(
+				"No built binary %s found, skipping completion; "+
+					"run again after build with -B to include it",
+				execName,
+			)
+		}
+
+		for _, shell := range completionShells {
+			_, err := getCompletionPath(shell, execName)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if binary == "" {
+				continue
+			}
+
+			logStep("Reading %s completion of %s...", shell, execName)
+
+			completion, err := getBinaryCompletion(binary, shell)
+			if err != nil {
+				logWarning(
+					"Can't get %s completion, skipping: %s", shell, err,
+				)
+
+				continue
+			}
+
+			file, err := prepareCompletionFile(
+				completion, shell, execName, dirName, isDryRun,
+			)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			files = append(files, file)
+		}
+	}
+
+	err = applyFileModes(files, rawFileModes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	backup := createBackupList(files, backupInclude, backupExclude)
+
+	archSources, err := parseArchSources(rawArchSources)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	archMap, err := parseArchMap(rawArchMap)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	archs, err := getGoArchList(goArchs, archMap)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sources := []pkgSource{}
+
+	binReleaseFile := ""
+	if isBinRelease {
+		sources = append(sources, pkgSource{URL: binReleaseURL, Hash: "SKIP"})
+
+		binReleaseFile = getBinReleaseFile(binReleaseURL, programName)
+	}
+
+	for _, source := range getUniqueList(rawSources) {
+		sources = append(sources, pkgSource{URL: source, Hash: "SKIP"})
+	}
+
+	dlAgents, err := parseDLAgents(rawDLAgents)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	protocols := append([]string{}, builtinSourceProtocols...)
+	for _, agent := range dlAgents {
+		protocols = append(protocols, strings.SplitN(agent, "::", 2)[0])
+	}
+
+	allSources := append([]pkgSource{}, sources...)
+	for _, archSource := range archSources {
+		allSources = append(allSources, archSource.Sources...)
+	}
+
+	for _, source := range allSources {
+		protocol := getSourceProtocol(source.URL)
+		if protocol != "" && !isStringInList(protocol, protocols) {
+			logWarning(
+				"Source %s uses protocol %s with no download agent, "+
+					"add it with --dlagent",
+				source.URL, protocol,
+			)
+		}
+	}
+
+	err = markSkipSums(skipSumNames, sources, archSources)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !doSkipRemoteSums && (len(sources) > 0 || len(archSources) > 0) {
+		logStep("Computing sums of remote sources...")
+
+		err = resolveRemoteSums(sources, dirName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, archSource := range archSources {
+			err = resolveRemoteSums(archSource.Sources, dirName)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	units := []string{}
+
+	if doCreateService {
+		services, err := getServices(
+			serviceData{
+				UnitName:     unitName,
+				Description:  description,
+				ExecName:     execName,
+				BinDir:       binDir,
+				ListenStream: svcSocket,
+				OnCalendar:   svcTimer,
+			},
+			serviceBinaries, args[`--svc-name`] != nil,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Binaries built by wildcard build or make are known only after
+		// build, so they can't be checked.
+		if !isWildcardBuild && !doUseMake {
+			mainBinary := ""
+			switch {
+			case isBinRelease || binaryName != "":
+				mainBinary = execName
+
+			case !isMeta:
+				mainBinary = strings.Replace(
+					builtBinaryName, "$_pkgname", programName, -1,
+				)
+			}
+
+			binaries := getInstalledBinaries(files, binDir, mainBinary)
+
+			for _, warning := range lintServiceBinaries(services, binaries) {
+				logWarning("Bad service: %s", warning)
+			}
+		}
+
+		unitDir, wantedBy := getUnitInstallTarget(isUserUnit)
+
+		for _, service := range services {
+			service.WantedBy = wantedBy
+
+			for _, unit := range getServiceUnits(service, unitDir) {
+				if isStringInList(unit.Name, units) {
+					log.Fatalf("duplicate service: %q", unit.Name)
+				}
+
+				units = append(units, unit.Name)
+
+				unitFileName := getUnitOutputName(unit, serviceOutput)
+
+				file, err := prepareUnitFile(
+					unit, service, unitFileName, dirName,
+					doSkipExisting, doForce, isDryRun,
+				)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				files = append(files, file)
+			}
+		}
+	}
+
+	if dropinUnit != "" {
+		if dropinUnit != filepath.Base(dropinUnit) ||
+			!strings.Contains(dropinUnit, ".") {
+			log.Fatalf("invalid drop-in unit name: %q", dropinUnit)
+		}
+
+		overrides, err := parseServiceOverrides(rawOverrides)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		unitDir, _ := getUnitInstallTarget(isUserUnit)
+
+		dropin, err := prepareDropinFile(
+			dropinUnit, overrides, unitDir, dirName, isDryRun,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		files = append(files, dropin)
+	}
+
+	if doCreateCompletionHook {
+		files, err = addCompletionHook(files, packageName, dirName, isDryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	installScript := ""
+
+	dirs, err := parseDirs(rawDirs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	chowns, err := parseChowns(rawChowns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if doCreateInstall || doEnableService || doCreateAppArmor ||
+		len(chowns) > 0 {
+		installScript = packageName + ".install"
+
+		contents := &bytes.Buffer{}
+		err = createInstallScript(contents, installData{
+			PkgName:     packageName,
+			Units:       units,
+			EnableUnits: doEnableService,
+			IsUserUnits: isUserUnit,
+
+			AppArmorProfile: apparmorProfile,
+			Chowns:          chowns,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = writeOutputFile(
+			filepath.Join(dirName, installScript), contents.Bytes(), isDryRun,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sortFilesByName(files[generatedFilesStart:])
+
+	err = checkSourceNames(files)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	noExtract, err := markNoExtract(
+		noExtractNames, files, sources, archSources,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Binaries built by wildcard build, make or go install are known only
+	// after build, so they are installed by build tools or found in GOPATH.
+	binaryInstalls := []string{}
+	switch {
+	case isBinRelease:
+		binaryInstalls = append(binaryInstalls, getBinaryInstall(
+			"$srcdir/"+binReleaseFile, binDir, execName,
+		))
+
+	case isMeta || isWildcardBuild || doUseMake || doGoInstall:
+
+	case binaryName != "":
+		binaryInstalls = append(binaryInstalls, getBinaryInstall(
+			"$srcdir/go/bin/"+builtBinaryName, binDir, binaryName,
+		))
+
+	default:
+		binaryInstalls = append(binaryInstalls, getBinaryInstall(
+			"$srcdir/go/bin/"+builtBinaryName, binDir, builtBinaryName,
+		))
+	}
+
+	pkgbuild := &bytes.Buffer{}
+
+	data := pkgData{
+		Maintainer:      maintainer,
+		CoMaintainers:   getUniqueList(coMaintainers),
+		PkgBase:         packageBase,
+		PkgName:         packageName,
+		PkgVer:          packageVersion,
+		PkgVerSed:       pkgVerSed,
+		PkgRel:          packageRelease,
+		ProgramName:     programName,
+		RepoURL:         safeRepoURL,
+		SourceDir:       sourceDir,
+		GoSrcDir:        goSrcDir,
+		RefKind:         refKind,
+		RefName:         refName,
+		IsShallow:       isShallow,
+		DefaultBranch:   defaultBranch,
+		Licenses:        licenses,
+		PkgDesc:         description,
+		Arch:            getArchList(archs, archSources, isMeta),
+		Files:           files,
+		Dirs:            dirs,
+		Sources:         sources,
+		ArchSources:     archSources,
+		NoExtract:       noExtract,
+		ValidPGPKeys:    pgpKeys,
+		Patches:         patches,
+		PatchStrip:      patchStrip,
+		IsDebugPackage:  doDebugPackage,
+		IsSplitDebug:    doSplitDebug,
+		InstallScript:   installScript,
+		ExtraVars:       extraVars,
+		GoDependencies:  goDependencies,
+		DLAgents:        dlAgents,
+		Backup:          backup,
+		IsWildcardBuild: isWildcardBuild,
+		VersionVarName:  versionVarName,
+		BinaryName:      binaryName,
+		BuiltBinaryName: builtBinaryName,
+		BinaryInstalls:  binaryInstalls,
+		BinDir:          binDir,
+		CmdDir:          cmdDir,
+		MainFile:        mainFile,
+		WorkspaceModule: workspaceModule,
+		GoFlags:         goFlags,
+		IsMeta:          isMeta,
+		IsBinRelease:    isBinRelease,
+		BinReleaseFile:  binReleaseFile,
+		ExecName:        execName,
+
+		IsPkgVerPlaceholder: isPkgVerPlaceholder,
+		GoProxy:             goProxy,
+		GoSumDB:             goSumDB,
+		IsReproducible:      isReproducible,
+		BuildJobs:           buildJobs,
+		UseMake:             doUseMake,
+		IsGoInstall:         doGoInstall,
+		MakeTarget:          makeTarget,
+		Dependencies:        dependencies,
+		MakeDependencies:    makeDependencies,
+		Provides:            provides,
+		Conflicts:           conflicts,
+	}
+
+	lintWarnings := lintPkgData(data)
+	for _, warning := range lintWarnings {
+		logWarning("PKGBUILD lint: %s", warning)
+	}
+
+	if isStrict && len(lintWarnings) > 0 {
+		log.Fatal("PKGBUILD lint failed")
+	}
+
+	err = createSnippedPkgbuild(
+		pkgbuild, data, pkgbuildPrepend, pkgbuildAppend,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if doShowDiff {
+		err = showDiff(pkgbuildPath, pkgbuild.Bytes())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if isDryRun {
+		if !doShowDiff {
+			os.Stdout.Write(pkgbuild.Bytes())
+		}
+	} else if !isPkgbuildSkipped {
+		err = ioutil.WriteFile(pkgbuildPath, pkgbuild.Bytes(), 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gitignorePath := filepath.Join(dirName, ".gitignore")
+	if doCreateGitignore && !isDryRun &&
+		!isSkippedExisting(gitignorePath, doSkipExisting) {
+		err = createGitignore(dirName, packageName, doForce)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if doPrintReport {
+		err = printReport(os.Stderr, packageReport{
+			PkgName:       packageName,
+			VersionSource: getVersionSource(data),
+			Files:         len(files),
+			Size:          getPackagedSize(dirName, files),
+			Dependencies:  dependencies,
+			Service:       doCreateService || dropinUnit != "",
+			Gitignore:     doCreateGitignore,
+			InstallScript: installScript != "",
+			Checksum:      "md5",
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if archiveName != "" && !isDryRun {
+		archiveFiles := getBuildDirFiles(outputName, installScript)
+
+		for _, file := range append(files, patches...) {
+			archiveFiles = append(archiveFiles, file.Name)
+		}
+
+		err = createArchive(archiveName, dirName, packageName, archiveFiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	runStageHook("post-gen")
+
+	buildOpts := buildOptions{
+		CleanUp:     doCleanUp,
+		Chroot:      doBuildInChroot,
+		ChrootDir:   chrootDir,
+		MakepkgConf: makepkgConf,
+	}
+
+	if doRunBuild && doImportPGPKeys && isDryRun {
+		for _, command := range getPGPImportCommands(pgpKeys) {
+			fmt.Println(formatCommand(command[0], command[1:]))
+		}
+	}
+
+	if doRunBuild && doImportPGPKeys && !isDryRun {
+		err = importPGPKeys(pgpKeys)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if doRunBuild && isDryRun {
+		printBuildCommand(dirName, buildOpts)
+
+		runStageHook("pre-build")
+		runStageHook("post-build")
+	}
+
+	if doRunBuild && !isDryRun {
+		err = runBuildWithHooks(dirName, buildOpts, hooks, packageName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if doAutoDepends && doRunBuild && !isDryRun {
+		logStep("Inspecting built binaries...")
+
+		binaries, err := getBuiltBinaries(dirName, packageName, binDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if len(binaries) == 0 {
+			logWarning("No built binaries found, skipping depends suggestion")
+		} else {
+			suggested, unresolved, err := suggestDependencies(binaries)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			for _, library := range unresolved {
+				logWarning("Can't find package owning library %s", library)
+			}
+
+			missing := []string{}
+			for _, dependency := range suggested {
+				if !isStringInList(dependency, dependencies) {
+					missing = append(missing, dependency)
+				}
+			}
+
+			switch {
+			case len(missing) == 0:
+				logSubStep("No additional depends suggested")
+
+			case doWriteDepends && !isPkgbuildSkipped:
+				logSubStep("Adding depends: %s", strings.Join(missing, ", "))
+
+				data.Dependencies = append(dependencies, missing...)
+
+				pkgbuild.Reset()
+
+				err = createSnippedPkgbuild(
+					pkgbuild, data, pkgbuildPrepend, pkgbuildAppend,
+				)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				err = ioutil.WriteFile(pkgbuildPath, pkgbuild.Bytes(), 0644)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				logWarning("Depends are changed, rebuild package to apply them")
+
+			default:
+				logSubStep(
+					"Suggested depends: -D %s",
+					strings.Join(append(dependencies, missing...), ","),
+				)
+			}
+		}
+	}
+
+	if doCleanUp {
+		err = cleanUp(dirName, packageName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if doWatch {
+		watchedFiles := append([]string{}, fileList...)
+		watchedFiles = append(watchedFiles, licenseFiles...)
+		watchedFiles = append(watchedFiles, patchNames...)
+
+		for _, name := range []string{
+			configTemplate, profileScript, prependName, appendName,
+			dependsFile, makeDependsFile, projectConfigName,
+		} {
+			if name != "" {
+				watchedFiles = append(watchedFiles, name)
+			}
+		}
+
+		watchedDirs := []string{dirName}
+		for _, tree := range trees {
+			watchedDirs = append(watchedDirs, strings.SplitN(tree, ":", 2)[0])
+		}
+
+		err = watchFiles(watchedFiles, watchedDirs)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// runBuildWithHooks runs build surrounded by pre-build and post-build hooks,
+// stopping at the first failure.
+func runBuildWithHooks(
+	dir string, options buildOptions, hooks map[string]string, pkgName string,
+) error {
+	err := runOptionalHook(hooks, "pre-build", pkgName, dir)
+	if err != nil {
+		return err
+	}
+
+	err = runBuild(dir, options)
+	if err != nil {
+		return err
+	}
+
+	return runOptionalHook(hooks, "post-build", pkgName, dir)
+}
+
+func runBuild(dir string, options buildOptions) error {
+	name, args := getBuildCommand(options)
+
+	if options.Chroot || options.ChrootDir != "" {
+		_, err := exec.LookPath(name)
+		if err != nil {
+			logWarning("Skipping chroot build: %s is not installed", name)
+			return nil
+		}
+	}
+
+	logStep("Running %s...", name)
+
+	ctx, cancel := newTimeoutContext(commandTimeout)
+	defer cancel()
+
+	cmd := execCommandContext(ctx, name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = dir
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf(
+			"%s failed: %s", name, getTimeoutError(ctx, commandTimeout, err),
+		)
+	}
+
+	return nil
+}
+
+// printBuildCommand prints command line which would be run to build package
+// in specified directory, without running it.
+func printBuildCommand(dir string, options buildOptions) {
+	name, args := getBuildCommand(options)
+	fmt.Printf(
+		"cd %s && %s\n", quoteShellArg(dir), formatCommand(name, args),
+	)
+}
+
+func getBuildCommand(options buildOptions) (string, []string) {
+	switch {
+	case options.ChrootDir != "":
+		return "makechrootpkg", []string{"-c", "-r", options.ChrootDir}
+	case options.Chroot:
+		return "extra-x86_64-build", []string{}
+	}
+
+	args := []string{"-f"}
+	if options.CleanUp {
+		args = append(args, "-c")
+	}
+
+	if options.MakepkgConf != "" {
+		args = append(args, "--config", options.MakepkgConf)
+	}
+
+	return "makepkg", args
+}
+
+// formatCommand returns command line which can be pasted into shell.
+func formatCommand(name string, args []string) string {
+	parts := []string{quoteShellArg(name)}
+	for _, arg := range args {
+		parts = append(parts, quoteShellArg(arg))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func quoteShellArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`;&|<>()*?[]#~!{}") {
+		return arg
+	}
+
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+func cleanUp(dir, pkgName string) error {
+	return os.RemoveAll(filepath.Join(dir, pkgName))
+}
+
+func copyLocalFiles(files []pkgFile, outDir string, refresh bool) error {
+	logStep("Preparing local files...")
+	for _, file := range files {
+		logSubStep("Including file in the package: %s", file.Path)
+
+		err := linkLocalFile(file, outDir, refresh)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyPatches(patches []pkgFile, outDir string, refresh bool) error {
+	logStep("Preparing patches...")
+	for _, patch := range patches {
+		logSubStep("Including patch: %s", patch.Path)
+
+		err := linkLocalFile(patch, outDir, refresh)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkLocalFile links file into output directory. Existing file is kept if
+// its contents are up to date, unless refresh is requested.
+func linkLocalFile(file pkgFile, outDir string, refresh bool) error {
+	targetName := filepath.Join(outDir, file.Name)
+
+	_, err := os.Lstat(targetName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if !refresh {
+			hash, err := getFileHash(targetName)
+			if err != nil {
+				return err
+			}
+
+			if hash == file.Hash {
+				return nil
+			}
+		}
+
+		logSubStep("Refreshing file: %s", targetName)
+
+		err = os.Remove(targetName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Link(file.Source, targetName)
+}
+
+// getLicenseDir returns package-relative directory for license files with
+// '<pkgname>' placeholder replaced by package name.
+func getLicenseDir(dir string, pkgName string) (string, error) {
+	dir = strings.Replace(dir, "<pkgname>", pkgName, -1)
+
+	if !isPackageRelativePath(dir) {
+		return "", fmt.Errorf(
+			"license directory must be package-relative: %q", dir,
+		)
+	}
+
+	return dir, nil
+}
+
+func prepareLicenseFile(name string, dir string) (pkgFile, error) {
+	hash, err := getFileHash(name)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return pkgFile{
+		Source: name,
+		Path:   filepath.Join(dir, filepath.Base(name)),
+		Name:   filepath.Base(name),
+		Hash:   hash,
+	}, nil
+}
+
+func prepareConfigFile(name string, pkgName string) (pkgFile, error) {
+	hash, err := getFileHash(name)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return pkgFile{
+		Source: name,
+		Path:   filepath.Join("etc", pkgName, "config.toml"),
+		Name:   "config.toml",
+		Hash:   hash,
+	}, nil
+}
+
+// prepareProfileFile returns file to be installed into 'etc/profile.d',
+// which is sourced by login shells only if its name ends with '.sh', so it
+// doesn't need to be executable.
+func prepareProfileFile(name string) (pkgFile, error) {
+	hash, err := getFileHash(name)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	profileName := filepath.Base(name)
+	if !strings.HasSuffix(profileName, ".sh") {
+		profileName += ".sh"
+	}
+
+	return pkgFile{
+		Source: name,
+		Path:   filepath.Join("etc", "profile.d", profileName),
+		Name:   profileName,
+		Hash:   hash,
+		Mode:   "0644",
+	}, nil
+}
+
+func prepareTreeFileList(tree string) ([]pkgFile, error) {
+	parts := strings.SplitN(tree, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf(
+			"invalid tree: %q, expected <SRC>:<DESTPREFIX>", tree,
+		)
+	}
+
+	root, prefix := parts[0], strings.Trim(parts[1], "/")
+
+	files := []pkgFile{}
+
+	err := filepath.Walk(
+		root,
+		func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			relative, err := filepath.Rel(root, name)
+			if err != nil {
+				return err
+			}
+
+			hash, err := getFileHash(name)
+			if err != nil {
+				return err
+			}
+
+			mode := "0644"
+			if info.Mode()&0111 != 0 {
+				mode = "0755"
+			}
+
+			target := filepath.ToSlash(filepath.Join(prefix, relative))
+
+			files = append(files, pkgFile{
+				Source: name,
+				Path:   target,
+				Name:   strings.Replace(target, "/", "_", -1),
+				Hash:   hash,
+				Mode:   mode,
+			})
+
+			return nil
+		},
+	)
+
+	return files, err
+}
+
+func preparePatchList(names []string) ([]pkgFile, error) {
+	patches := []pkgFile{}
+
+	for _, name := range names {
+		hash, err := getFileHash(name)
+		if err != nil {
+			return nil, err
+		}
+
+		patches = append(patches, pkgFile{
+			Source: name,
+			Path:   name,
+			Name:   path.Base(name),
+			Hash:   hash,
+		})
+	}
+
+	return patches, nil
+}
+
+func createOutputDir(dirName string, mode os.FileMode) error {
+	if _, err := os.Stat(dirName); !os.IsNotExist(err) {
+		return err
+	}
+
+	err := os.Mkdir(dirName, mode)
+	if err != nil {
+		return err
+	}
+
+	// mode passed to mkdir is affected by umask
+	return os.Chmod(dirName, mode)
+}
+
+// isPackageRelativePath checks that path points inside of the package, so it
+// can be used as install path.
+func isPackageRelativePath(target string) bool {
+	target = path.Clean(target)
+	return !path.IsAbs(target) && target != "." && target != ".." &&
+		!strings.HasPrefix(target, "../")
+}
+
+// applyFileModes overrides install modes of included files using specs in
+// form <PATH>:<OCTAL>.
+func applyFileModes(files []pkgFile, specs []string) error {
+	for _, spec := range specs {
+		index := strings.LastIndex(spec, ":")
+		if index <= 0 {
+			return fmt.Errorf(
+				"invalid file mode: %q, expected <PATH>:<OCTAL>", spec,
+			)
+		}
+
+		target := strings.TrimPrefix(spec[:index], "/")
+
+		mode, err := parseFileMode(spec[index+1:])
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for i := range files {
+			if files[i].Path == target {
+				files[i].Mode = fmt.Sprintf("%04o", mode)
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("no included file %q to set mode for", target)
+		}
+	}
+
+	return nil
+}
+
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid octal file mode: %q", value)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+func createPkgbuild(output io.Writer, data pkgData) error {
+	logStep("Creating PKGBUILD...")
+
+	err := writeGeneratedMarker(output)
+	if err != nil {
+		return err
+	}
+
+	return pkgbuildTemplate.Execute(output, data)
+}
+
+// createSnippedPkgbuild writes PKGBUILD with snippets from --prepend-pkgbuild
+// and --append-pkgbuild inserted verbatim before and after generated body.
+func createSnippedPkgbuild(
+	output io.Writer, data pkgData, head []byte, tail []byte,
+) error {
+	_, err := output.Write(head)
+	if err != nil {
+		return err
+	}
+
+	err = createPkgbuild(output, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = output.Write(tail)
+
+	return err
+}
+
+func writeGeneratedMarker(output io.Writer) error {
+	_, err := fmt.Fprintf(output, "%s v%s\n", generatedMarker, version)
+	return err
+}
+
+// isSkippedExisting checks if output file should not be written because it
+// already exists and skipping of existing files is requested.
+func isSkippedExisting(path string, skip bool) bool {
+	if !skip {
+		return false
+	}
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	logSubStep("Skipping existing file: %s", path)
+
+	return true
+}
+
+// checkOverwrite ensures that existing file was generated by go-makepkg, so
+// hand-written files are not overwritten accidentally.
+func checkOverwrite(path string, force bool) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if force || bytes.Contains(contents, []byte(generatedMarker)) {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"refusing to overwrite %s, which is not generated by go-makepkg, "+
+			"use --force to overwrite it",
+		path,
+	)
+}
+
+func showDiff(path string, contents []byte) error {
+	logStep("Comparing with existing %s...", path)
+
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		logSubStep("No existing file to compare with")
+		return nil
+	}
+
+	diff, err := getDiff(path, contents)
+	if err != nil {
+		return err
+	}
+
+	if len(diff) == 0 {
+		logSubStep("No changes")
+		return nil
+	}
+
+	if !isColored {
+		_, err = os.Stdout.Write(diff)
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(diff), "\n"), "\n")
+	for _, line := range lines {
+		color := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			color = "1"
+		case strings.HasPrefix(line, "+"):
+			color = "32"
+		case strings.HasPrefix(line, "-"):
+			color = "31"
+		case strings.HasPrefix(line, "@@"):
+			color = "36"
+		}
+
+		if color != "" {
+			line = "\x1b[" + color + "m" + line + "\x1b[0m"
+		}
+
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// getDiff returns unified diff between existing file and new contents, which
+// is empty if there are no changes.
+func getDiff(path string, contents []byte) ([]byte, error) {
+	cmd := exec.Command(
+		"diff", "-u", "--label", path, "--label", path+" (new)", path, "-",
+	)
+	cmd.Stdin = bytes.NewReader(contents)
+
+	diff, err := cmd.Output()
+	if err != nil {
+		// diff exits with status 1 when files differ
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok || exitErr.Sys().(syscall.WaitStatus).ExitStatus() != 1 {
+			return nil, err
+		}
+	}
+
+	return diff, nil
+}
+
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// readSnippet reads contents of the file to be inserted into PKGBUILD,
+// ensuring it ends with newline. Empty name yields no contents.
+func readSnippet(name string) ([]byte, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	contents, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) > 0 && !bytes.HasSuffix(contents, []byte("\n")) {
+		contents = append(contents, '\n')
+	}
+
+	return contents, nil
+}
+
+func createUnitFile(
+	output io.Writer, unit serviceUnit, data serviceData,
+) error {
+	logStep("Creating unit file %s...", unit.Name)
+
+	err := writeGeneratedMarker(output)
+	if err != nil {
+		return err
+	}
+
+	return unit.Template.Execute(output, data)
+}
+
+// prepareUnitFile writes unit file into output directory and returns it as
+// packaged file. Existing unit file is kept as is if skipping of existing
+// files is requested.
+func prepareUnitFile(
+	unit serviceUnit, data serviceData, unitFileName string, dirName string,
+	skipExisting bool, force bool, dryRun bool,
+) (pkgFile, error) {
+	unitPath := filepath.Join(dirName, unitFileName)
+
+	file := pkgFile{
+		Name: unitFileName,
+		Path: unit.Path,
+	}
+
+	if isSkippedExisting(unitPath, skipExisting) {
+		hash, err := getFileHash(unitPath)
+		if err != nil {
+			return pkgFile{}, err
+		}
+
+		file.Hash = hash
+
+		return file, nil
+	}
+
+	if !dryRun {
+		err := checkOverwrite(unitPath, force)
+		if err != nil {
+			return pkgFile{}, err
+		}
+	}
+
+	contents := &bytes.Buffer{}
+	err := createUnitFile(contents, unit, data)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	file.Hash, err = writeOutputFile(unitPath, contents.Bytes(), dryRun)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return file, nil
+}
+
+// prepareDropinFile writes drop-in with overrides of specified unit into
+// output directory and returns it as file installed into '<UNIT>.d'
+// directory next to units.
+func prepareDropinFile(
+	unit string, overrides []pkgVar, unitDir string, dirName string,
+	dryRun bool,
+) (pkgFile, error) {
+	dropinName := unit + ".override.conf"
+
+	contents := &bytes.Buffer{}
+	err := createDropin(contents, dropinData{Overrides: overrides})
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	hash, err := writeOutputFile(
+		filepath.Join(dirName, dropinName), contents.Bytes(), dryRun,
+	)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return pkgFile{
+		Name: dropinName,
+		Path: path.Join(unitDir, unit+".d", "override.conf"),
+		Hash: hash,
+		Mode: "0644",
+	}, nil
+}
+
+func createDropin(output io.Writer, data dropinData) error {
+	logStep("Creating service drop-in...")
+	return dropinTemplate.Execute(output, data)
+}
+
+func createCompletionHook(output io.Writer, data completionHookData) error {
+	logStep("Creating completion hook...")
+	return completionHookTemplate.Execute(output, data)
+}
+
+// addCompletionHook writes pacman hook, which rebuilds zsh completion dump,
+// and adds it to package files, but only if package ships zsh completions.
+func addCompletionHook(
+	files []pkgFile, pkgName string, dirName string, dryRun bool,
+) ([]pkgFile, error) {
+	if !hasZshCompletions(files) {
+		logStep("No zsh completions included, skipping completion hook...")
+		return files, nil
+	}
+
+	hookName := pkgName + "-zsh-completion.hook"
+
+	contents := &bytes.Buffer{}
+	err := createCompletionHook(contents, completionHookData{
+		CompletionDir: zshCompletionDir,
+		DumpDir:       "/var/cache/zsh",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := writeOutputFile(
+		filepath.Join(dirName, hookName), contents.Bytes(), dryRun,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(files, pkgFile{
+		Name: hookName,
+		Path: filepath.Join("usr/share/libalpm/hooks", hookName),
+		Hash: hash,
+		Mode: "0644",
+	}), nil
+}
+
+func hasZshCompletions(files []pkgFile) bool {
+	for _, file := range files {
+		if strings.HasPrefix(file.Path, zshCompletionDir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prepareAppArmorProfile writes AppArmor profile stub into output directory
+// and returns it as file installed into 'etc/apparmor.d', so it's backed up.
+func prepareAppArmorProfile(
+	data apparmorData, dirName string, dryRun bool,
+) (pkgFile, error) {
+	profileName := data.PkgName + ".apparmor"
+
+	contents := &bytes.Buffer{}
+	err := createAppArmorProfile(contents, data)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	hash, err := writeOutputFile(
+		filepath.Join(dirName, profileName), contents.Bytes(), dryRun,
+	)
+	if err != nil {
+		return pkgFile{}, err
+	}
+
+	return pkgFile{
+		Name: profileName,
+		Path: path.Join("etc/apparmor.d", data.PkgName),
+		Hash: hash,
+		Mode: "0644",
+	}, nil
+}
+
+func createAppArmorProfile(output io.Writer, data apparmorData) error {
+	logStep("Creating AppArmor profile...")
+	return apparmorTemplate.Execute(output, data)
+}
+
+func createInstallScript(output io.Writer, data installData) error {
+	logStep("Creating install script...")
+	return installTemplate.Execute(output, data)
+}
+
+func createGitignore(dirName string, pkgName string, force bool) error {
+	logStep("Creating .gitignore...")
+
+	name := filepath.Join(dirName, ".gitignore")
+
+	err := checkOverwrite(name, force)
+	if err != nil {
+		return err
+	}
+
+	output, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer output.Close()
+
+	err = writeGeneratedMarker(output)
+	if err != nil {
+		return err
+	}
+
+	return gitignoreTemplate.Execute(output, gitignoreData{
+		PkgName: pkgName,
+	})
+}
+
+func loadTemplates(dir string) error {
+	logStep("Loading templates...")
+
+	overrides := []templateOverride{
+		{"PKGBUILD.tmpl", &pkgbuildTemplate},
+		{"service.tmpl", &serviceTemplate},
+		{"socket.tmpl", &socketTemplate},
+		{"timer.tmpl", &timerTemplate},
+		{"gitignore.tmpl", &gitignoreTemplate},
+		{"install.tmpl", &installTemplate},
+		{"apparmor.tmpl", &apparmorTemplate},
+		{"dropin.tmpl", &dropinTemplate},
+		{"manpage.tmpl", &manPageTemplate},
+	}
+
+	for _, override := range overrides {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, override.Name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return err
+		}
+
+		logSubStep("Using template: %s", override.Name)
+
+		tpl, err := template.New(override.Name).Parse(string(contents))
+		if err != nil {
+			return err
+		}
+
+		*override.Template = tpl
+	}
+
+	return nil
+}
+
+func sortFilesByName(files []pkgFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+}
+
+// getBuildDirFiles returns names of files, which are placed into output
+// directory only to build package and are not packaged themselves.
+func getBuildDirFiles(pkgbuildName string, installScript string) []string {
+	names := []string{pkgbuildName, ".SRCINFO", ".gitignore"}
+	if installScript != "" {
+		names = append(names, installScript)
+	}
+
+	return names
+}
+
+// prepareFileList returns packaged files for specified names. Files with
+// names reserved for build directory files are skipped if specified as is
+// and rejected if located in subdirectory, because they would overwrite
+// build directory files in output directory.
+func prepareFileList(
+	names []string, outDir string, reserved []string,
+) ([]pkgFile, error) {
+	files := []pkgFile{}
+
+	for _, name := range names {
+		stat, err := os.Stat(name)
+		if os.IsExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if stat.IsDir() {
+			continue
+		}
+
+		if name == "PKGBUILD" || isStringInList(name, reserved) {
+			continue
+		}
+
+		if strings.HasPrefix(name, outDir) {
+			continue
+		}
+
+		if isStringInList(path.Base(name), reserved) {
+			return nil, fmt.Errorf(
+				"file %s conflicts with build directory file %s",
+				name, path.Base(name),
+			)
+		}
+
+		hash, err := getFileHash(name)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, pkgFile{
+			Source: name,
+			Path:   name,
+			Name:   path.Base(name),
+			Hash:   hash,
+		})
+	}
+
+	return files, nil
+}
+
+// writeOutputFile writes generated contents to specified file in output
+// directory and returns their hash. Nothing is written in dry run, but hash
+// is still returned, so PKGBUILD can be rendered.
+func writeOutputFile(
+	name string, contents []byte, dryRun bool,
+) (string, error) {
+	if !dryRun {
+		err := ioutil.WriteFile(name, contents, 0644)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", md5.Sum(contents)), nil
+}
+
+// checkSourceNames ensures that different files don't share name in build
+// directory, otherwise one of them silently replaces another in package.
+func checkSourceNames(files []pkgFile) error {
+	named := map[string]pkgFile{}
+	for _, file := range files {
+		other, ok := named[file.Name]
+		if ok && (other.Source == "" || other.Source != file.Source) {
+			return fmt.Errorf(
+				"files %s and %s have the same name %s in build directory",
+				other.Path, file.Path, file.Name,
+			)
+		}
+
+		named[file.Name] = file
+	}
+
+	return nil
+}
+
+func getFileHash(path string) (string, error) {
+	hash := md5.New()
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	var output io.Writer = hash
+	if stat.Size() >= progressMinSize {
+		progress := newProgressWriter("Hashing "+path, stat.Size())
+		defer progress.Done()
+
+		output = io.MultiWriter(hash, progress)
+	}
+
+	_, err = io.Copy(output, file)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
-func createBackupList(files []pkgFile) []string {
+// createBackupList returns backup paths for packaged files under 'etc/' and
+// additionally included paths, except excluded ones.
+func createBackupList(files []pkgFile, include, exclude []string) []string {
 	logStep("Checking backup files...")
 
 	backup := []string{}
 	for _, file := range files {
-		logSubStep("Adding to backup: %s", file.Path)
 		if strings.HasPrefix(file.Path, "etc/") {
 			backup = append(backup, file.Path)
 		}
 	}
 
-	return backup
+	for _, path := range include {
+		path = strings.TrimPrefix(path, "/")
+		if !isStringInList(path, backup) {
+			backup = append(backup, path)
+		}
+	}
+
+	result := []string{}
+	for _, path := range backup {
+		if isBackupExcluded(path, exclude) {
+			logSubStep("Excluding from backup: %s", path)
+			continue
+		}
+
+		logSubStep("Adding to backup: %s", path)
+		result = append(result, path)
+	}
+
+	return result
+}
+
+// isBackupExcluded checks if path or any of its parent directories matches
+// one of exclude glob patterns, so 'etc/app/cache/*' excludes whole subtree.
+func isBackupExcluded(target string, exclude []string) bool {
+	for _, pattern := range exclude {
+		pattern = strings.TrimPrefix(pattern, "/")
+
+		for name := target; name != "." && name != "/"; name = path.Dir(name) {
+			matched, err := path.Match(pattern, name)
+			if err == nil && matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getUnitFileName returns file name for the systemd unit of the given kind
+// (service, socket, timer), so all units of the package share same base name.
+func getUnitFileName(unitName string, kind string) string {
+	return unitName + "." + kind
+}
+
+// getInstalledBinaries returns names of binaries installed by package: main
+// binary, if any, and included files placed into binaries directory.
+func getInstalledBinaries(
+	files []pkgFile, binDir string, mainBinary string,
+) []string {
+	binaries := []string{}
+	for _, file := range files {
+		if path.Dir(file.Path) == binDir {
+			binaries = append(binaries, path.Base(file.Path))
+		}
+	}
+
+	if mainBinary != "" {
+		binaries = append(binaries, mainBinary)
+	}
+
+	return binaries
+}
+
+// getServices returns base service running main binary of the package or,
+// if binaries are specified with --svc-for, one service per binary derived
+// from base one. Unit name set with --svc-name is used only for single
+// binary, otherwise units are named after binaries.
+func getServices(
+	base serviceData, binaries []string, hasUnitName bool,
+) ([]serviceData, error) {
+	if len(binaries) == 0 {
+		return []serviceData{base}, nil
+	}
+
+	services := []serviceData{}
+	for _, binary := range binaries {
+		if binary == "" || strings.Contains(binary, "/") {
+			return nil, fmt.Errorf("invalid service binary: %q", binary)
+		}
+
+		service := base
+		service.UnitName = binary
+		service.Description = fmt.Sprintf("%s (%s)", base.Description, binary)
+		service.ExecName = binary
+
+		if len(binaries) == 1 && hasUnitName {
+			service.UnitName = base.UnitName
+		}
+
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+// getUnitOutputName returns name of the unit file in output directory, which
+// is set by --svc-output for service unit and is unit name otherwise.
+func getUnitOutputName(unit serviceUnit, serviceOutput string) string {
+	if serviceOutput != "" && unit.Kind == "service" {
+		return serviceOutput
+	}
+
+	return unit.Name
+}
+
+// getUnitInstallTarget returns directory where units are installed and
+// target which they are wanted by, for system or user units.
+func getUnitInstallTarget(isUserUnit bool) (string, string) {
+	if isUserUnit {
+		return "usr/lib/systemd/user", "default.target"
+	}
+
+	return "usr/lib/systemd/system", "multi-user.target"
+}
+
+// getServiceUnits returns unit files generated for specified service: the
+// service itself and its socket and timer companions, if requested, along
+// with their install paths inside specified unit directory.
+func getServiceUnits(service serviceData, unitDir string) []serviceUnit {
+	kinds := []string{"service"}
+	templates := []*template.Template{serviceTemplate}
+
+	if service.ListenStream != "" {
+		kinds = append(kinds, "socket")
+		templates = append(templates, socketTemplate)
+	}
+
+	if service.OnCalendar != "" {
+		kinds = append(kinds, "timer")
+		templates = append(templates, timerTemplate)
+	}
+
+	units := []serviceUnit{}
+	for i, kind := range kinds {
+		name := getUnitFileName(service.UnitName, kind)
+
+		units = append(units, serviceUnit{
+			Kind:     kind,
+			Name:     name,
+			Path:     filepath.Join(unitDir, name),
+			Template: templates[i],
+		})
+	}
+
+	return units
+}
+
+// getPkgVer returns specified package version or, if there is no version
+// source at all, placeholder version to be edited in PKGBUILD. Packages built
+// from git get version from pkgver() function.
+func getPkgVer(version string, isVCS bool) (string, bool) {
+	if version != "" || isVCS {
+		return version, false
+	}
+
+	logWarning(
+		"No version source specified, edit pkgver in PKGBUILD " +
+			"or use --pkgver",
+	)
+
+	return "0.0.0", true
+}
+
+func isValidPkgVer(version string) bool {
+	return pkgVerRegexp.MatchString(version)
+}
+
+// isValidBuildJobs checks that number of build jobs is positive integer.
+func isValidBuildJobs(jobs string) bool {
+	number, err := strconv.Atoi(jobs)
+	return err == nil && number > 0
+}
+
+// isValidPkgRel checks that release is positive number with optional single
+// fractional part, like '1' or '1.1'.
+func isValidPkgRel(release string) bool {
+	if !pkgRelRegexp.MatchString(release) {
+		return false
+	}
+
+	value, err := strconv.ParseFloat(release, 64)
+
+	return err == nil && value > 0
+}
+
+func parseRef(ref string) (string, string, error) {
+	if ref == "" {
+		return "", "", nil
+	}
+
+	parts := strings.SplitN(ref, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf(
+			"invalid ref: %q, expected branch=<BRANCH>, tag=<TAG> "+
+				"or commit=<COMMIT>", ref,
+		)
+	}
+
+	switch parts[0] {
+	case "branch", "tag", "commit":
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf(
+			"invalid ref kind: %q, expected branch, tag or commit", parts[0],
+		)
+	}
+}
+
+func parseExtraVars(specs []string) ([]pkgVar, error) {
+	vars := []pkgVar{}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf(
+				"invalid variable: %q, expected <NAME>=<VALUE>", spec,
+			)
+		}
+
+		if !shellIdentifierRegexp.MatchString(parts[0]) {
+			return nil, fmt.Errorf(
+				"invalid variable name: %q, must be valid shell identifier",
+				parts[0],
+			)
+		}
+
+		vars = append(vars, pkgVar{Name: parts[0], Value: parts[1]})
+	}
+
+	return vars, nil
+}
+
+func parseServiceOverrides(specs []string) ([]pkgVar, error) {
+	overrides := []pkgVar{}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf(
+				"invalid service override: %q, expected <KEY>=<VALUE>", spec,
+			)
+		}
+
+		overrides = append(overrides, pkgVar{Name: parts[0], Value: parts[1]})
+	}
+
+	return overrides, nil
+}
+
+func parseDirs(specs []string) ([]pkgDir, error) {
+	dirs := []pkgDir{}
+
+	for _, spec := range specs {
+		target, rawMode := spec, "0755"
+		if index := strings.LastIndex(spec, ":"); index >= 0 {
+			target, rawMode = spec[:index], spec[index+1:]
+		}
+
+		target = strings.TrimPrefix(target, "/")
+		if !isPackageRelativePath(target) ||
+			strings.ContainsAny(target, "\"$`\\") {
+			return nil, fmt.Errorf(
+				"invalid directory: %q, expected <PATH>:<OCTAL>", spec,
+			)
+		}
+
+		mode, err := parseFileMode(rawMode)
+		if err != nil {
+			return nil, err
+		}
+
+		dirs = append(dirs, pkgDir{
+			Path: path.Clean(target),
+			Mode: fmt.Sprintf("%04o", mode),
+		})
+	}
+
+	return dirs, nil
+}
+
+func parseChowns(specs []string) ([]pkgChown, error) {
+	chowns := []pkgChown{}
+
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) != 3 || !isPackageRelativePath(parts[0]) ||
+			!userNameRegexp.MatchString(parts[1]) ||
+			!userNameRegexp.MatchString(parts[2]) {
+			return nil, fmt.Errorf(
+				"invalid ownership: %q, expected <PATH>:<USER>:<GROUP>", spec,
+			)
+		}
+
+		chowns = append(chowns, pkgChown{
+			Path:  path.Clean(parts[0]),
+			User:  parts[1],
+			Group: parts[2],
+		})
+	}
+
+	return chowns, nil
+}
+
+func parseArchSources(specs []string) ([]pkgArchSources, error) {
+	archSources := []pkgArchSources{}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf(
+				"invalid architecture-specific source: %q, "+
+					"expected <ARCH>:<URL>", spec,
+			)
+		}
+
+		arch, source := parts[0], pkgSource{URL: parts[1], Hash: "SKIP"}
+
+		found := false
+		for i := range archSources {
+			if archSources[i].Arch == arch {
+				archSources[i].Sources = append(
+					archSources[i].Sources, source,
+				)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			archSources = append(archSources, pkgArchSources{
+				Arch:    arch,
+				Sources: []pkgSource{source},
+			})
+		}
+	}
+
+	return archSources, nil
+}
+
+// markSkipSums forces 'SKIP' sums for sources with specified file names,
+// so they are not downloaded to compute sums.
+func markSkipSums(
+	names []string,
+	sources []pkgSource,
+	archSources []pkgArchSources,
+) error {
+	for _, name := range names {
+		found := false
+
+		allSources := [][]pkgSource{sources}
+		for _, archSource := range archSources {
+			allSources = append(allSources, archSource.Sources)
+		}
+
+		for _, list := range allSources {
+			for i := range list {
+				if getSourceFileName(list[i].URL) == name {
+					list[i].SkipSum = true
+					list[i].Hash = "SKIP"
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("no source with file name %q to skip sum", name)
+		}
+	}
+
+	return nil
+}
+
+func markNoExtract(
+	names []string,
+	files []pkgFile,
+	sources []pkgSource,
+	archSources []pkgArchSources,
+) ([]string, error) {
+	noExtract := []string{}
+
+	for _, name := range names {
+		found := false
+
+		for i := range files {
+			if files[i].Name == name {
+				files[i].NoExtract = true
+				found = true
+			}
+		}
+
+		for i := range sources {
+			if getSourceFileName(sources[i].URL) == name {
+				sources[i].NoExtract = true
+				found = true
+			}
+		}
+
+		for _, archSource := range archSources {
+			for i := range archSource.Sources {
+				source := &archSource.Sources[i]
+				if getSourceFileName(source.URL) == name {
+					source.NoExtract = true
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf(
+				"no source with file name %q to exclude from extraction",
+				name,
+			)
+		}
+
+		if !isStringInList(name, noExtract) {
+			noExtract = append(noExtract, name)
+		}
+	}
+
+	return noExtract, nil
+}
+
+func getSourceFileName(source string) string {
+	if index := strings.Index(source, "::"); index >= 0 {
+		return source[:index]
+	}
+
+	return path.Base(source)
+}
+
+func getSourceURL(source string) string {
+	if index := strings.Index(source, "::"); index >= 0 {
+		return source[index+2:]
+	}
+
+	return source
+}
+
+func getArchList(
+	baseArchs []string, archSources []pkgArchSources, isMeta bool,
+) []string {
+	if isMeta {
+		return []string{"any"}
+	}
+
+	archs := append([]string{}, baseArchs...)
+	if len(archs) == 0 {
+		archs = []string{"i686", "x86_64"}
+	}
+
+	for _, archSource := range archSources {
+		if !isStringInList(archSource.Arch, archs) {
+			archs = append(archs, archSource.Arch)
+		}
+	}
+
+	return archs
+}
+
+// getBinReleaseFile returns name of the released binary in srcdir: archives
+// are extracted by makepkg and are expected to contain binary named after
+// program, otherwise downloaded file is binary itself.
+func getBinReleaseFile(releaseURL string, programName string) string {
+	name := getSourceFileName(releaseURL)
+	if isArchiveName(name) {
+		return programName
+	}
+
+	return name
+}
+
+func isArchiveName(name string) bool {
+	for _, suffix := range []string{
+		".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tar.xz", ".tar.zst", ".zip",
+	} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isStringInList(value string, list []string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
 }
 
 func getPackageNameFromRepoURL(repo string) string {
@@ -426,20 +3701,46 @@ func trimWildcardFromRepoURL(repo string) (string, bool) {
 }
 
 func logSubStep(msg string, data ...interface{}) {
-	fmt.Printf("  \x1b[1;34m-> \x1b[39m%s\n", fmt.Sprintf(msg, data...))
+	if isQuiet {
+		return
+	}
+
+	fmt.Printf("  %s%s\n", colorize("1;34", "-> "), fmt.Sprintf(msg, data...))
+}
+
+func logWarning(msg string, data ...interface{}) {
+	fmt.Printf(
+		"%s%s\n", colorize("1;33", "==> WARNING: "), fmt.Sprintf(msg, data...),
+	)
 }
 
 func logStep(msg string, data ...interface{}) {
-	fmt.Printf("\x1b[1;32m==> \x1b[39m%s\n", fmt.Sprintf(msg, data...))
+	if isQuiet {
+		return
+	}
+
+	fmt.Printf("%s%s\n", colorize("1;32", "==> "), fmt.Sprintf(msg, data...))
+}
+
+func replaceUsageDefaults(usage string, defaults config) string {
+	return strings.NewReplacer(
+		"$MAINTAINER", formatUsageDefault(defaults.Maintainer),
+		"$LICENSE", formatUsageDefault(defaults.License),
+		"$DEPENDS", formatUsageDefault(
+			strings.Join(defaults.Dependencies, ","),
+		),
+		"$MAKEDEPENDS", formatUsageDefault(
+			strings.Join(defaults.MakeDependencies, ","),
+		),
+	).Replace(usage)
 }
 
-func replaceUsageDefaults(usage string) string {
-	maintainer, _ := getMaintainerInfo()
-	if maintainer != "" {
-		maintainer = " [default: " + maintainer + "]"
+func formatUsageDefault(value string) string {
+	if value == "" {
+		return ""
 	}
 
-	return strings.Replace(usage, "$MAINTAINER", maintainer, -1)
+	return " [default: " + value + "]"
 }
 
 func getMaintainerInfo() (string, error) {
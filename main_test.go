@@ -0,0 +1,1740 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docopt/docopt-go"
+)
+
+// writeTestFile creates file with specified contents and mode, creating
+// parent directories.
+func writeTestFile(
+	t *testing.T, name string, contents string, mode os.FileMode,
+) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(name, []byte(contents), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chdirTest changes working directory to specified one until the end of
+// the test.
+func chdirTest(t *testing.T, dir string) {
+	t.Helper()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+	})
+}
+
+// captureOutput returns everything printed to stdout by specified function,
+// like log messages.
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+
+	output, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer output.Close()
+
+	stdout := os.Stdout
+	os.Stdout = output
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+
+	contents, err := ioutil.ReadFile(output.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(contents)
+}
+
+func TestPrepareTreeFileListMapsNestedTree(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "dist")
+
+	writeTestFile(t, filepath.Join(root, "bin", "app"), "#!/bin/sh\n", 0755)
+	writeTestFile(
+		t, filepath.Join(root, "share", "data", "a.txt"), "a\n", 0644,
+	)
+
+	files, err := prepareTreeFileList(root + ":/opt/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type installed struct {
+		Path string
+		Name string
+		Mode string
+	}
+
+	actual := []installed{}
+	for _, file := range files {
+		actual = append(actual, installed{file.Path, file.Name, file.Mode})
+
+		if file.Hash == "" {
+			t.Errorf("%s: hash is not set", file.Path)
+		}
+	}
+
+	expected := []installed{
+		{"opt/app/bin/app", "opt_app_bin_app", "0755"},
+		{"opt/app/share/data/a.txt", "opt_app_share_data_a.txt", "0644"},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestCheckSourceNamesDetectsTreeNameConflict(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "dist")
+
+	writeTestFile(t, filepath.Join(root, "a_b"), "first\n", 0644)
+	writeTestFile(t, filepath.Join(root, "a", "b"), "second\n", 0644)
+
+	files, err := prepareTreeFileList(root + ":opt/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = checkSourceNames(files)
+	if err == nil {
+		t.Fatal("expected conflict of opt/app/a_b and opt/app/a/b")
+	}
+}
+
+func TestCheckSourceNamesAllowsSameSource(t *testing.T) {
+	files := []pkgFile{
+		{Source: "README.md", Path: "README.md", Name: "README.md"},
+		{
+			Source: "README.md",
+			Path:   "usr/share/doc/README.md",
+			Name:   "README.md",
+		},
+		{Source: "etc/a/config", Path: "etc/a/config", Name: "config"},
+	}
+
+	err := checkSourceNames(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files = append(
+		files,
+		pkgFile{Source: "etc/b/config", Path: "etc/b/config", Name: "config"},
+	)
+
+	err = checkSourceNames(files)
+	if err == nil {
+		t.Fatal("expected conflict of etc/a/config and etc/b/config")
+	}
+}
+
+func TestGetDiffContainsChangedLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "PKGBUILD")
+	writeTestFile(t, name, "pkgname=tool\npkgrel=1\narch=('any')\n", 0644)
+
+	diff, err := getDiff(
+		name, []byte("pkgname=tool\npkgrel=2\narch=('any')\n"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"-pkgrel=1\n", "+pkgrel=2\n"} {
+		if !strings.Contains(string(diff), line) {
+			t.Errorf("diff doesn't contain %q:\n%s", line, diff)
+		}
+	}
+
+	if strings.Contains(string(diff), "-pkgname=tool") {
+		t.Errorf("diff contains unchanged line:\n%s", diff)
+	}
+
+	diff, err = getDiff(
+		name, []byte("pkgname=tool\npkgrel=1\narch=('any')\n"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff) != 0 {
+		t.Errorf("expected empty diff, got:\n%s", diff)
+	}
+}
+
+func TestWriteOutputFileSkipsWriteInDryRun(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "tool.service")
+
+	dryHash, err := writeOutputFile(name, []byte("[Unit]\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(name)
+	if !os.IsNotExist(err) {
+		t.Fatalf("file is written in dry run: %v", err)
+	}
+
+	hash, err := writeOutputFile(name, []byte("[Unit]\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileHash, err := getFileHash(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dryHash != hash || hash != fileHash {
+		t.Errorf(
+			"hashes differ: dry run %s, written %s, file %s",
+			dryHash, hash, fileHash,
+		)
+	}
+}
+
+func TestGetServiceUnitsUsesUnitNameForCompanions(t *testing.T) {
+	service := serviceData{
+		UnitName:     "foo-daemon",
+		ListenStream: "/run/foo.sock",
+		OnCalendar:   "daily",
+	}
+
+	units := getServiceUnits(service, "usr/lib/systemd/system")
+
+	paths := []string{}
+	for _, unit := range units {
+		paths = append(paths, unit.Path)
+	}
+
+	expected := []string{
+		"usr/lib/systemd/system/foo-daemon.service",
+		"usr/lib/systemd/system/foo-daemon.socket",
+		"usr/lib/systemd/system/foo-daemon.timer",
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %q, got %q", expected, paths)
+	}
+}
+
+func TestGetServiceUnitsWithoutCompanions(t *testing.T) {
+	units := getServiceUnits(
+		serviceData{UnitName: "foo"}, "usr/lib/systemd/user",
+	)
+
+	if len(units) != 1 || units[0].Path != "usr/lib/systemd/user/foo.service" {
+		t.Fatalf("expected single service unit, got %+v", units)
+	}
+}
+
+func TestCreateUnitFileRendersTimer(t *testing.T) {
+	service := serviceData{
+		UnitName:   "foo",
+		ExecName:   "foo",
+		BinDir:     "usr/bin",
+		OnCalendar: "daily",
+	}
+
+	for _, unit := range getServiceUnits(service, "usr/lib/systemd/system") {
+		contents := &strings.Builder{}
+		err := createUnitFile(contents, unit, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]string{
+			"service": "Type=oneshot\n",
+			"timer":   "OnCalendar=daily\n",
+		}[unit.Kind]
+
+		if !strings.Contains(contents.String(), expected) {
+			t.Fatalf(
+				"expected %s unit to contain %q, got:\n%s",
+				unit.Kind, expected, contents,
+			)
+		}
+	}
+}
+
+func TestLoadTemplatesOverridesOnlyServiceTemplate(t *testing.T) {
+	defaultService, defaultPkgbuild := serviceTemplate, pkgbuildTemplate
+	defer func() {
+		serviceTemplate, pkgbuildTemplate = defaultService, defaultPkgbuild
+	}()
+
+	dir := t.TempDir()
+	writeTestFile(
+		t, filepath.Join(dir, "service.tmpl"),
+		"ExecStart=/opt/{{.ExecName}}\n", 0644,
+	)
+
+	err := loadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkgbuildTemplate != defaultPkgbuild {
+		t.Fatal("expected built-in PKGBUILD template to be kept")
+	}
+
+	contents := &strings.Builder{}
+	err = serviceTemplate.Execute(contents, serviceData{ExecName: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contents.String() != "ExecStart=/opt/foo\n" {
+		t.Fatalf("expected overridden service, got %q", contents)
+	}
+}
+
+func TestLoadTemplatesRejectsInvalidTemplate(t *testing.T) {
+	defaultService := serviceTemplate
+	defer func() {
+		serviceTemplate = defaultService
+	}()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "service.tmpl"), "{{.Foo", 0644)
+
+	err := loadTemplates(dir)
+	if err == nil {
+		t.Fatal("expected error for invalid template")
+	}
+
+	if serviceTemplate != defaultService {
+		t.Fatal("expected built-in service template to be kept")
+	}
+}
+
+// prepareTestSource writes source file with specified contents, replacing
+// previous one like editors do, and returns it as packaged file.
+func prepareTestSource(t *testing.T, name string, contents string) pkgFile {
+	t.Helper()
+
+	os.Remove(name)
+	writeTestFile(t, name, contents, 0644)
+
+	hash, err := getFileHash(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pkgFile{
+		Source: name,
+		Path:   "etc/foo/foo.conf",
+		Name:   filepath.Base(name),
+		Hash:   hash,
+	}
+}
+
+func TestCopyLocalFilesUpdatesChangedSource(t *testing.T) {
+	source, outDir := filepath.Join(t.TempDir(), "foo.conf"), t.TempDir()
+
+	file := prepareTestSource(t, source, "old\n")
+	err := copyLocalFiles([]pkgFile{file}, outDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file = prepareTestSource(t, source, "new\n")
+	err = copyLocalFiles([]pkgFile{file}, outDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(outDir, "foo.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "new\n" {
+		t.Fatalf("expected updated contents, got %q", contents)
+	}
+}
+
+func TestCopyLocalFilesReplacesExistingFileOnlyWithRefresh(t *testing.T) {
+	source, outDir := filepath.Join(t.TempDir(), "foo.conf"), t.TempDir()
+	target := filepath.Join(outDir, "foo.conf")
+
+	file := prepareTestSource(t, source, "old\n")
+	writeTestFile(t, target, "old\n", 0644)
+
+	for _, refresh := range []bool{false, true} {
+		err := copyLocalFiles([]pkgFile{file}, outDir, refresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sourceInfo, err := os.Stat(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		targetInfo, err := os.Stat(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if os.SameFile(sourceInfo, targetInfo) != refresh {
+			t.Fatalf(
+				"refresh: %t: expected target linked to source: %t",
+				refresh, refresh,
+			)
+		}
+	}
+
+	err := ioutil.WriteFile(source, []byte("new\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "new\n" {
+		t.Fatalf("expected refreshed contents, got %q", contents)
+	}
+}
+
+func TestParseArchSourcesGroupsSourcesByArch(t *testing.T) {
+	archSources, err := parseArchSources([]string{
+		"x86_64:https://example.com/a",
+		"aarch64:https://example.com/b",
+		"x86_64:https://example.com/c",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgArchSources{
+		{Arch: "x86_64", Sources: []pkgSource{
+			{URL: "https://example.com/a", Hash: "SKIP"},
+			{URL: "https://example.com/c", Hash: "SKIP"},
+		}},
+		{Arch: "aarch64", Sources: []pkgSource{
+			{URL: "https://example.com/b", Hash: "SKIP"},
+		}},
+	}
+
+	if !reflect.DeepEqual(archSources, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, archSources)
+	}
+}
+
+func TestParseArchSourcesRejectsInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"x86_64", ":https://example.com", "x86_64:"} {
+		_, err := parseArchSources([]string{spec})
+		if err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestMarkNoExtractMarksOnlyMatchingSources(t *testing.T) {
+	files := []pkgFile{{Name: "foo.conf"}}
+	sources := []pkgSource{
+		{URL: "https://example.com/blob.tar.gz"},
+		{URL: "https://example.com/src.tar.gz"},
+	}
+	archSources := []pkgArchSources{
+		{Arch: "x86_64", Sources: []pkgSource{
+			{URL: "font.zip::https://example.com/download?id=1"},
+		}},
+	}
+
+	noExtract, err := markNoExtract(
+		[]string{"blob.tar.gz", "font.zip"}, files, sources, archSources,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"blob.tar.gz", "font.zip"}
+	if !reflect.DeepEqual(noExtract, expected) {
+		t.Fatalf("expected %q, got %q", expected, noExtract)
+	}
+
+	if files[0].NoExtract || !sources[0].NoExtract || sources[1].NoExtract ||
+		!archSources[0].Sources[0].NoExtract {
+		t.Fatalf(
+			"unexpected extraction marks: %+v %+v %+v",
+			files, sources, archSources,
+		)
+	}
+}
+
+func TestMarkNoExtractRejectsUnknownName(t *testing.T) {
+	_, err := markNoExtract([]string{"missing.zip"}, nil, nil, nil)
+	if err == nil {
+		t.Fatal("expected error for unknown source")
+	}
+}
+
+func TestParseExtraVarsKeepsOrder(t *testing.T) {
+	vars, err := parseExtraVars([]string{
+		"_modname=foo", "_commit=abc=def", "_empty=",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgVar{
+		{Name: "_modname", Value: "foo"},
+		{Name: "_commit", Value: "abc=def"},
+		{Name: "_empty", Value: ""},
+	}
+
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, vars)
+	}
+}
+
+func TestParseExtraVarsRejectsInvalidName(t *testing.T) {
+	for _, spec := range []string{"1foo=bar", "foo-bar=baz", "foo"} {
+		_, err := parseExtraVars([]string{spec})
+		if err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCreateBackupListMergesIncludesAndExcludes(t *testing.T) {
+	files := []pkgFile{
+		{Path: "etc/foo/config.toml"},
+		{Path: "etc/foo/defaults.toml"},
+		{Path: "usr/bin/foo"},
+	}
+
+	tests := []struct {
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{
+			nil, nil,
+			[]string{"etc/foo/config.toml", "etc/foo/defaults.toml"},
+		},
+		{
+			[]string{"/var/lib/foo/state.toml", "etc/foo/config.toml"}, nil,
+			[]string{
+				"etc/foo/config.toml",
+				"etc/foo/defaults.toml",
+				"var/lib/foo/state.toml",
+			},
+		},
+		{
+			nil, []string{"/etc/foo/defaults.toml"},
+			[]string{"etc/foo/config.toml"},
+		},
+		{
+			[]string{"var/lib/foo/state.toml"},
+			[]string{"etc/foo/defaults.toml", "var/lib/foo/state.toml"},
+			[]string{"etc/foo/config.toml"},
+		},
+	}
+
+	for _, test := range tests {
+		backup := createBackupList(files, test.include, test.exclude)
+		if !reflect.DeepEqual(backup, test.expected) {
+			t.Fatalf(
+				"include %q, exclude %q: expected %q, got %q",
+				test.include, test.exclude, test.expected, backup,
+			)
+		}
+	}
+}
+
+func TestCreateBackupListExcludesGlobSubtree(t *testing.T) {
+	files := []pkgFile{
+		{Path: "etc/app/app.conf"},
+		{Path: "etc/app/cache/index"},
+		{Path: "etc/app/cache/blobs/1"},
+		{Path: "etc/app/plugins/a.conf"},
+	}
+
+	tests := []struct {
+		exclude  []string
+		expected []string
+	}{
+		{
+			[]string{"etc/app/cache/*"},
+			[]string{"etc/app/app.conf", "etc/app/plugins/a.conf"},
+		},
+		{
+			[]string{"/etc/app/*/*.conf"},
+			[]string{
+				"etc/app/app.conf",
+				"etc/app/cache/index",
+				"etc/app/cache/blobs/1",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		backup := createBackupList(files, nil, test.exclude)
+		if !reflect.DeepEqual(backup, test.expected) {
+			t.Errorf(
+				"exclude %q: expected %q, got %q",
+				test.exclude, test.expected, backup,
+			)
+		}
+	}
+}
+
+func TestParseRefRejectsInvalidRef(t *testing.T) {
+	for _, ref := range []string{"devel", "branch=", "head=devel"} {
+		_, _, err := parseRef(ref)
+		if err == nil {
+			t.Fatalf("expected error for %q", ref)
+		}
+	}
+}
+
+func TestPrepareConfigFileInstallsIntoEtcBackup(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "example.toml")
+	writeTestFile(t, name, "listen = \":8080\"\n", 0644)
+
+	file, err := prepareConfigFile(name, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Path != "etc/foo/config.toml" || file.Name != "config.toml" {
+		t.Fatalf("unexpected install path: %+v", file)
+	}
+
+	// md5 of contents written above
+	if file.Hash != "9fbbcb32c9145f463a36536e1b430662" {
+		t.Fatalf("unexpected hash: %q", file.Hash)
+	}
+
+	backup := createBackupList([]pkgFile{file}, nil, nil)
+	if !reflect.DeepEqual(backup, []string{"etc/foo/config.toml"}) {
+		t.Fatalf("expected config in backup, got %q", backup)
+	}
+}
+
+func TestCreateUnitFileUsesBinaryName(t *testing.T) {
+	service := serviceData{
+		UnitName: "awesome-tool",
+		ExecName: "at",
+		BinDir:   getBinDir(defaultPrefix),
+	}
+
+	contents := &strings.Builder{}
+	err := createUnitFile(
+		contents, getServiceUnits(service, "usr/lib/systemd/system")[0],
+		service,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(contents.String(), "ExecStart=/usr/bin/at\n") {
+		t.Fatalf("expected ExecStart of binary name, got:\n%s", contents)
+	}
+}
+
+func TestRunBuildUsesChrootCommand(t *testing.T) {
+	bin := t.TempDir()
+	writeTestFile(
+		t, filepath.Join(bin, "extra-x86_64-build"), "#!/bin/sh\n", 0755,
+	)
+
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	commands := fakeCommands(t, "exit 0")
+
+	err := runBuild(t.TempDir(), buildOptions{Chroot: true, CleanUp: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{{"extra-x86_64-build"}}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, *commands)
+	}
+}
+
+func TestRunBuildUsesMakepkgWithoutChroot(t *testing.T) {
+	commands := fakeCommands(t, "exit 0")
+
+	err := runBuild(t.TempDir(), buildOptions{CleanUp: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{{"makepkg", "-f", "-c"}}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, *commands)
+	}
+}
+
+func TestRunBuildSkipsMissingChrootTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	commands := fakeCommands(t, "exit 0")
+
+	err := runBuild(t.TempDir(), buildOptions{ChrootDir: "/var/lib/chroot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*commands) != 0 {
+		t.Fatalf("expected no commands, got %q", *commands)
+	}
+}
+
+func TestRunBuildReturnsBuildFailure(t *testing.T) {
+	fakeCommands(t, "exit 1")
+
+	err := runBuild(t.TempDir(), buildOptions{})
+	if err == nil {
+		t.Fatal("expected error for failed build")
+	}
+}
+
+func TestRunBuildPassesMakepkgConf(t *testing.T) {
+	commands := fakeCommands(t, "exit 0")
+
+	err := runBuild(
+		t.TempDir(), buildOptions{MakepkgConf: "/etc/makepkg-custom.conf"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"makepkg", "-f", "--config", "/etc/makepkg-custom.conf"},
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, *commands)
+	}
+}
+
+func TestPrintBuildCommandDoesNotRunBuild(t *testing.T) {
+	tests := []struct {
+		options  buildOptions
+		expected string
+	}{
+		{
+			buildOptions{CleanUp: true, MakepkgConf: "/etc/my makepkg.conf"},
+			"cd 'build dir' && makepkg -f -c --config '/etc/my makepkg.conf'\n",
+		},
+		{
+			buildOptions{Chroot: true},
+			"cd 'build dir' && extra-x86_64-build\n",
+		},
+		{
+			buildOptions{ChrootDir: "/var/lib/chroot"},
+			"cd 'build dir' && makechrootpkg -c -r /var/lib/chroot\n",
+		},
+	}
+
+	for _, test := range tests {
+		commands := fakeCommands(t, "exit 0")
+
+		output := captureOutput(t, func() {
+			printBuildCommand("build dir", test.options)
+		})
+
+		if output != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, output)
+		}
+
+		if len(*commands) != 0 {
+			t.Errorf("expected no commands, got %q", *commands)
+		}
+	}
+}
+
+func TestCreateOutputDirUsesCustomMode(t *testing.T) {
+	mode, err := parseFileMode("0775")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirName := filepath.Join(t.TempDir(), "build")
+
+	err = createOutputDir(dirName, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(dirName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0775 {
+		t.Fatalf("expected mode 0775, got %o", info.Mode().Perm())
+	}
+}
+
+func TestParseFileModeRejectsInvalidMode(t *testing.T) {
+	for _, value := range []string{"", "0778", "rwxr-xr-x", "01777", "-1"} {
+		_, err := parseFileMode(value)
+		if err == nil {
+			t.Fatalf("expected error for %q", value)
+		}
+	}
+}
+
+func TestAddCompletionHookRequiresZshCompletions(t *testing.T) {
+	dirName := t.TempDir()
+	files := []pkgFile{
+		{Path: "usr/bin/foo"},
+		{Path: "usr/share/bash-completion/completions/foo"},
+	}
+
+	hooked, err := addCompletionHook(files, "foo", dirName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hooked) != len(files) {
+		t.Fatalf("expected no completion hook, got %+v", hooked)
+	}
+
+	files = append(files, pkgFile{Path: zshCompletionDir + "/_foo"})
+
+	hooked, err = addCompletionHook(files, "foo", dirName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hook := hooked[len(hooked)-1]
+	if len(hooked) != len(files)+1 ||
+		hook.Path != "usr/share/libalpm/hooks/foo-zsh-completion.hook" {
+		t.Fatalf("expected completion hook, got %+v", hooked)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dirName, hook.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(
+		string(contents), "Target = "+zshCompletionDir+"/*\n",
+	) {
+		t.Fatalf("unexpected completion hook:\n%s", contents)
+	}
+}
+
+func TestGetUnitInstallTargetForUserUnits(t *testing.T) {
+	tests := []struct {
+		isUserUnit bool
+		unitPath   string
+		wantedBy   string
+	}{
+		{false, "usr/lib/systemd/system/foo.service", "multi-user.target"},
+		{true, "usr/lib/systemd/user/foo.service", "default.target"},
+	}
+
+	for _, test := range tests {
+		unitDir, wantedBy := getUnitInstallTarget(test.isUserUnit)
+
+		service := serviceData{UnitName: "foo", WantedBy: wantedBy}
+		unit := getServiceUnits(service, unitDir)[0]
+
+		if unit.Path != test.unitPath {
+			t.Errorf("expected %q, got %q", test.unitPath, unit.Path)
+		}
+
+		contents := &strings.Builder{}
+		err := createUnitFile(contents, unit, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(
+			contents.String(), "WantedBy="+test.wantedBy+"\n",
+		) {
+			t.Errorf("expected %s in:\n%s", test.wantedBy, contents)
+		}
+	}
+}
+
+func TestGetPkgVerUsesPlaceholderWithoutVersionSource(t *testing.T) {
+	version, isPlaceholder := "", false
+	output := captureOutput(t, func() {
+		version, isPlaceholder = getPkgVer("", false)
+	})
+
+	if version != "0.0.0" || !isPlaceholder {
+		t.Fatalf("expected placeholder version, got %q", version)
+	}
+
+	if !strings.Contains(output, "WARNING: No version source specified") {
+		t.Fatalf("expected warning, got %q", output)
+	}
+
+	contents := renderPkgbuild(t, pkgData{
+		IsMeta:              true,
+		PkgVer:              version,
+		IsPkgVerPlaceholder: isPlaceholder,
+	})
+
+	assertContains(t, contents, "pkgver=0.0.0 # TODO: set version\n")
+}
+
+func TestGetPkgVerKeepsVersionSource(t *testing.T) {
+	tests := []struct {
+		version string
+		isVCS   bool
+	}{
+		{"1.2.3", false},
+		{"1.2.3", true},
+		{"", true},
+	}
+
+	for _, test := range tests {
+		output := captureOutput(t, func() {
+			version, isPlaceholder := getPkgVer(test.version, test.isVCS)
+			if version != test.version || isPlaceholder {
+				t.Errorf("%+v: unexpected placeholder %q", test, version)
+			}
+		})
+
+		if output != "" {
+			t.Errorf("%+v: expected no warning, got %q", test, output)
+		}
+	}
+}
+
+func TestGetLicenseDir(t *testing.T) {
+	args, err := docopt.Parse(
+		usage, []string{"desc", "repo"}, false, "", false, false,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir      string
+		expected string
+	}{
+		{args[`--license-install-dir`].(string), "usr/share/licenses/foo"},
+		{"usr/share/doc/<pkgname>", "usr/share/doc/foo"},
+	}
+
+	name := filepath.Join(t.TempDir(), "LICENSE")
+	writeTestFile(t, name, "license", 0644)
+
+	for _, test := range tests {
+		dir, err := getLicenseDir(test.dir, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := prepareLicenseFile(name, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if file.Path != test.expected+"/LICENSE" {
+			t.Errorf("%q: expected %q, got %q", test.dir, test.expected, file.Path)
+		}
+	}
+}
+
+func TestGetLicenseDirRejectsNonRelativeDir(t *testing.T) {
+	for _, dir := range []string{"/usr/share/doc/foo", "../foo", "."} {
+		_, err := getLicenseDir(dir, "foo")
+		if err == nil {
+			t.Errorf("expected error for %q", dir)
+		}
+	}
+}
+
+func TestGetServicesCreatesServicePerBinary(t *testing.T) {
+	services, err := getServices(
+		serviceData{
+			UnitName:    "foo",
+			Description: "Foo daemons",
+			ExecName:    "foo",
+			BinDir:      "usr/bin",
+		},
+		[]string{"foo-server", "foo-worker"}, false,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"foo-server.service": "ExecStart=/usr/bin/foo-server\n",
+		"foo-worker.service": "ExecStart=/usr/bin/foo-worker\n",
+	}
+
+	if len(services) != len(expected) {
+		t.Fatalf("expected %d services, got %+v", len(expected), services)
+	}
+
+	for _, service := range services {
+		unit := getServiceUnits(service, "usr/lib/systemd/system")[0]
+
+		contents := &strings.Builder{}
+		err := createUnitFile(contents, unit, service)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(contents.String(), expected[unit.Name]) {
+			t.Errorf(
+				"expected %q in %s:\n%s", expected[unit.Name], unit.Name, contents,
+			)
+		}
+	}
+}
+
+func TestGetServicesUsesUnitNameForSingleBinary(t *testing.T) {
+	services, err := getServices(
+		serviceData{UnitName: "foo-daemon"}, []string{"foo-server"}, true,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services) != 1 || services[0].UnitName != "foo-daemon" ||
+		services[0].ExecName != "foo-server" {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+}
+
+func TestGetServicesRejectsInvalidBinary(t *testing.T) {
+	_, err := getServices(serviceData{}, []string{"bin/foo"}, false)
+	if err == nil {
+		t.Fatal("expected error for invalid binary")
+	}
+}
+
+func TestReadListFileSkipsCommentsAndBlankLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "depends")
+	writeTestFile(t, name, `# runtime dependencies
+glibc
+
+  openssl  # for TLS
+	
+sqlite
+glibc
+`, 0644)
+
+	values, err := readListFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dependencies := getUniqueList(
+		append(parseCommaList("zlib,openssl"), values...),
+	)
+
+	expected := []string{"zlib", "openssl", "glibc", "sqlite"}
+	if !reflect.DeepEqual(dependencies, expected) {
+		t.Fatalf("expected %q, got %q", expected, dependencies)
+	}
+}
+
+func TestGetSourceFileNameOfRenamedSource(t *testing.T) {
+	tests := []struct {
+		source string
+		name   string
+		url    string
+	}{
+		{
+			"https://example.com/data.tar.gz",
+			"data.tar.gz", "https://example.com/data.tar.gz",
+		},
+		{
+			"foo-data.tar.gz::https://example.com/data.tar.gz",
+			"foo-data.tar.gz", "https://example.com/data.tar.gz",
+		},
+	}
+
+	for _, test := range tests {
+		name, url := getSourceFileName(test.source), getSourceURL(test.source)
+		if name != test.name || url != test.url {
+			t.Errorf(
+				"%s: expected %q and %q, got %q and %q",
+				test.source, test.name, test.url, name, url,
+			)
+		}
+	}
+}
+
+func TestGetBinReleaseFile(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://example.com/v1.0/foo-linux-amd64.tar.gz", "foo"},
+		{"https://example.com/v1.0/foo-linux-amd64.zip", "foo"},
+		{"https://example.com/v1.0/foo-linux-amd64", "foo-linux-amd64"},
+		{"foo::https://example.com/download?os=linux", "foo"},
+	}
+
+	for _, test := range tests {
+		name := getBinReleaseFile(test.url, "foo")
+		if name != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.url, test.expected, name)
+		}
+	}
+}
+
+func TestIsValidBuildJobs(t *testing.T) {
+	tests := []struct {
+		jobs     string
+		expected bool
+	}{
+		{"1", true},
+		{"16", true},
+		{"0", false},
+		{"-2", false},
+		{"four", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if isValidBuildJobs(test.jobs) != test.expected {
+			t.Errorf("%q: expected valid: %t", test.jobs, test.expected)
+		}
+	}
+}
+
+func TestGetUnitOutputNameKeepsInstallPath(t *testing.T) {
+	units := getServiceUnits(
+		serviceData{UnitName: "foo", OnCalendar: "daily"},
+		"usr/lib/systemd/system",
+	)
+
+	expected := []struct {
+		name string
+		path string
+	}{
+		{"foo.service.custom", "usr/lib/systemd/system/foo.service"},
+		{"foo.timer", "usr/lib/systemd/system/foo.timer"},
+	}
+
+	for i, unit := range units {
+		name := getUnitOutputName(unit, "foo.service.custom")
+		if name != expected[i].name || unit.Path != expected[i].path {
+			t.Errorf(
+				"expected %q installed to %q, got %q installed to %q",
+				expected[i].name, expected[i].path, name, unit.Path,
+			)
+		}
+	}
+
+	if name := getUnitOutputName(units[0], ""); name != "foo.service" {
+		t.Errorf("expected default name %q, got %q", "foo.service", name)
+	}
+}
+
+func TestPrepareAppArmorProfileIsBackedUp(t *testing.T) {
+	dirName := t.TempDir()
+
+	profile, err := prepareAppArmorProfile(
+		apparmorData{PkgName: "foo", ExecName: "foo", BinDir: "usr/bin"},
+		dirName, false,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.Path != "etc/apparmor.d/foo" || profile.Name != "foo.apparmor" {
+		t.Fatalf("unexpected profile: %+v", profile)
+	}
+
+	hash, err := getFileHash(filepath.Join(dirName, profile.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != profile.Hash {
+		t.Fatalf("expected hash %q, got %q", hash, profile.Hash)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dirName, profile.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(contents), "/usr/bin/foo flags=(complain) {") {
+		t.Fatalf("unexpected profile contents:\n%s", contents)
+	}
+
+	backup := createBackupList([]pkgFile{profile}, nil, nil)
+	if !reflect.DeepEqual(backup, []string{"etc/apparmor.d/foo"}) {
+		t.Fatalf("expected profile in backup, got %q", backup)
+	}
+}
+
+func TestIsValidPkgRel(t *testing.T) {
+	tests := []struct {
+		release  string
+		expected bool
+	}{
+		{"1", true},
+		{"12", true},
+		{"1.1", true},
+		{"0", false},
+		{"1.x", false},
+		{"1.1.1", false},
+		{"abc", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if isValidPkgRel(test.release) != test.expected {
+			t.Errorf("%q: expected valid: %t", test.release, test.expected)
+		}
+	}
+}
+
+func TestParseChownsRejectsInvalidSpec(t *testing.T) {
+	specs := []string{
+		"var/lib/foo:foo",
+		"/var/lib/foo:foo:foo",
+		"../foo:foo:foo",
+		"var/lib/foo:Foo Bar:foo",
+		"var/lib/foo:foo:foo:foo",
+	}
+
+	for _, spec := range specs {
+		_, err := parseChowns([]string{spec})
+		if err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestPrepareDropinFileInstallsIntoDropinDir(t *testing.T) {
+	overrides, err := parseServiceOverrides([]string{
+		"Environment=FOO=bar",
+		"Nice=10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirName := t.TempDir()
+
+	dropin, err := prepareDropinFile(
+		"nginx.service", overrides, "usr/lib/systemd/system", dirName, false,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPath := "usr/lib/systemd/system/nginx.service.d/override.conf"
+	if dropin.Path != expectedPath {
+		t.Fatalf("expected path %q, got %q", expectedPath, dropin.Path)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dirName, dropin.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[Service]\nEnvironment=FOO=bar\nNice=10\n"
+	if string(contents) != expected {
+		t.Fatalf("expected %q, got %q", expected, contents)
+	}
+
+	hash, err := getFileHash(filepath.Join(dirName, dropin.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dropin.Hash != hash {
+		t.Fatalf("expected hash %q, got %q", hash, dropin.Hash)
+	}
+}
+
+func TestParseServiceOverridesRejectsInvalidOverride(t *testing.T) {
+	for _, spec := range []string{"Nice", "=10"} {
+		_, err := parseServiceOverrides([]string{spec})
+		if err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestGeneratedFilesStartWithMarker(t *testing.T) {
+	marker := generatedMarker + " v" + version + "\n"
+
+	pkgbuild := &bytes.Buffer{}
+	err := createPkgbuild(pkgbuild, pkgData{PkgName: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unit := &bytes.Buffer{}
+	err = createUnitFile(
+		unit, getServiceUnits(serviceData{UnitName: "foo"}, "")[0],
+		serviceData{UnitName: "foo", ExecName: "foo", BinDir: "usr/bin"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirName := t.TempDir()
+	err = createGitignore(dirName, "foo", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitignore, err := ioutil.ReadFile(filepath.Join(dirName, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range map[string]string{
+		"PKGBUILD":   pkgbuild.String(),
+		"unit":       unit.String(),
+		".gitignore": string(gitignore),
+	} {
+		if !strings.HasPrefix(contents, marker) {
+			t.Errorf("%s: expected %q at start of:\n%s", name, marker, contents)
+		}
+	}
+}
+
+func TestCheckOverwriteProtectsHandWrittenFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	generated := filepath.Join(dir, "generated")
+	writeTestFile(t, generated, generatedMarker+" v1.0\nfoo\n", 0644)
+
+	handWritten := filepath.Join(dir, "hand-written")
+	writeTestFile(t, handWritten, "# Maintainer: John Doe\n", 0644)
+
+	tests := []struct {
+		path      string
+		force     bool
+		isAllowed bool
+	}{
+		{filepath.Join(dir, "missing"), false, true},
+		{generated, false, true},
+		{handWritten, false, false},
+		{handWritten, true, true},
+	}
+
+	for _, test := range tests {
+		err := checkOverwrite(test.path, test.force)
+		if (err == nil) != test.isAllowed {
+			t.Errorf(
+				"%s: force: %t: expected allowed: %t, got error: %v",
+				test.path, test.force, test.isAllowed, err,
+			)
+		}
+	}
+}
+
+func TestCreateGitignoreKeepsHandWrittenFile(t *testing.T) {
+	dirName := t.TempDir()
+	name := filepath.Join(dirName, ".gitignore")
+
+	writeTestFile(t, name, "*.log\n", 0644)
+
+	err := createGitignore(dirName, "foo", false)
+	if err == nil {
+		t.Fatal("expected error for hand-written .gitignore")
+	}
+
+	contents, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "*.log\n" {
+		t.Fatalf("expected file to be kept, got %q", contents)
+	}
+}
+
+func TestMarkSkipSumsKeepsSumsAligned(t *testing.T) {
+	requested := []string{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			requested = append(requested, request.URL.Path)
+			fmt.Fprint(writer, "payload\n")
+		},
+	))
+	defer server.Close()
+
+	sources := []pkgSource{
+		{URL: server.URL + "/foo-1.0.tar.gz"},
+		{URL: server.URL + "/nightly.tar.gz"},
+		{URL: server.URL + "/bar-1.0.tar.gz"},
+	}
+	archSources := []pkgArchSources{{
+		Arch:    "x86_64",
+		Sources: []pkgSource{{URL: server.URL + "/nightly.tar.gz"}},
+	}}
+
+	err := markSkipSums([]string{"nightly.tar.gz"}, sources, archSources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !archSources[0].Sources[0].SkipSum {
+		t.Fatal("expected architecture-specific source to be skipped")
+	}
+
+	err = resolveRemoteSums(sources, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/foo-1.0.tar.gz", "/bar-1.0.tar.gz"}
+	if !reflect.DeepEqual(requested, expected) {
+		t.Fatalf("expected downloads %q, got %q", expected, requested)
+	}
+
+	contents := renderPkgbuild(t, pkgData{
+		IsBinRelease: true,
+		Files:        []pkgFile{{Name: "foo.conf", Hash: "0000"}},
+		Sources:      sources,
+	})
+
+	// md5 of payload served above
+	assertContains(
+		t, contents,
+		"md5sums=(\n"+
+			"\t'0000'\n"+
+			"\t'249c850f62ea50feb918b095fc56d763'\n"+
+			"\t'SKIP'\n"+
+			"\t'249c850f62ea50feb918b095fc56d763'\n"+
+			")\n",
+	)
+}
+
+func TestMarkSkipSumsRejectsUnknownName(t *testing.T) {
+	err := markSkipSums(
+		[]string{"missing.tar.gz"},
+		[]pkgSource{{URL: "https://example.com/foo.tar.gz"}}, nil,
+	)
+	if err == nil {
+		t.Fatal("expected error for unknown source")
+	}
+}
+
+func TestSkipExistingPreservesExistingOutputFiles(t *testing.T) {
+	dirName := t.TempDir()
+
+	pkgbuildPath := filepath.Join(dirName, "PKGBUILD")
+	servicePath := filepath.Join(dirName, "foo.service")
+
+	writeTestFile(t, pkgbuildPath, "# hand-maintained\n", 0644)
+	writeTestFile(t, servicePath, "[Service]\nExecStart=/bin/true\n", 0644)
+
+	if !isSkippedExisting(pkgbuildPath, true) {
+		t.Fatal("expected existing PKGBUILD to be skipped")
+	}
+
+	if isSkippedExisting(pkgbuildPath, false) {
+		t.Fatal("expected PKGBUILD not to be skipped without flag")
+	}
+
+	gitignorePath := filepath.Join(dirName, ".gitignore")
+	if isSkippedExisting(gitignorePath, true) {
+		t.Fatal("expected missing .gitignore not to be skipped")
+	}
+
+	service := serviceData{
+		UnitName:   "foo",
+		ExecName:   "foo",
+		BinDir:     "usr/bin",
+		OnCalendar: "daily",
+	}
+
+	for _, unit := range getServiceUnits(service, "usr/lib/systemd/system") {
+		file, err := prepareUnitFile(
+			unit, service, unit.Name, dirName, true, false, false,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hash, err := getFileHash(filepath.Join(dirName, unit.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if file.Hash != hash {
+			t.Errorf("%s: expected hash %q, got %q", unit.Name, hash, file.Hash)
+		}
+	}
+
+	for name, expected := range map[string]string{
+		"PKGBUILD":    "# hand-maintained\n",
+		"foo.service": "[Service]\nExecStart=/bin/true\n",
+	} {
+		contents, err := ioutil.ReadFile(filepath.Join(dirName, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(contents) != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, contents)
+		}
+	}
+
+	timer, err := ioutil.ReadFile(filepath.Join(dirName, "foo.timer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContains(t, string(timer), generatedMarker, "OnCalendar=daily\n")
+}
+
+func TestPrepareFileListNeverPackagesBuildDirFiles(t *testing.T) {
+	dir := t.TempDir()
+	chdirTest(t, dir)
+
+	for _, name := range []string{
+		".gitignore", "PKGBUILD", "foo.install", "foo.conf",
+		"etc/.gitignore",
+	} {
+		writeTestFile(t, name, name+"\n", 0644)
+	}
+
+	reserved := getBuildDirFiles("PKGBUILD", "foo.install")
+
+	files, err := prepareFileList(
+		[]string{".gitignore", "PKGBUILD", "foo.install", "foo.conf"},
+		"build", reserved,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name != "foo.conf" {
+		t.Fatalf("expected only foo.conf to be packaged, got %+v", files)
+	}
+
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:   "foo",
+		Files:     files,
+		Backup:    createBackupList(files, nil, nil),
+		SourceDir: "foo",
+	})
+
+	if strings.Contains(contents, ".gitignore") {
+		t.Fatalf("expected no .gitignore in:\n%s", contents)
+	}
+
+	_, err = prepareFileList([]string{"etc/.gitignore"}, "build", reserved)
+	if err == nil {
+		t.Fatal("expected conflict with build directory .gitignore")
+	}
+}
+
+func TestApplyFileModesOverridesOnlySpecifiedFile(t *testing.T) {
+	files := []pkgFile{
+		{Name: "foo.conf", Path: "etc/foo/foo.conf", Mode: "0644"},
+		{Name: "secret.conf", Path: "etc/foo/secret.conf", Mode: "0644"},
+		{Name: "foo.sh", Path: "usr/lib/foo/foo.sh"},
+	}
+
+	err := applyFileModes(files, []string{"/etc/foo/secret.conf:600"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := renderPkgbuild(t, pkgData{PkgName: "foo", Files: files})
+
+	assertContains(
+		t, contents,
+		"install -DT -m0644 \"$srcdir/foo.conf\" "+
+			"\"$pkgdir/etc/foo/foo.conf\"\n",
+		"install -DT -m0600 \"$srcdir/secret.conf\" "+
+			"\"$pkgdir/etc/foo/secret.conf\"\n",
+		"install -DT -m0755 \"$srcdir/foo.sh\" "+
+			"\"$pkgdir/usr/lib/foo/foo.sh\"\n",
+	)
+}
+
+func TestApplyFileModesRejectsInvalidSpec(t *testing.T) {
+	specs := []string{
+		"etc/foo/foo.conf",
+		"etc/foo/foo.conf:999",
+		"etc/foo/foo.conf:1755",
+		"etc/foo/missing.conf:600",
+	}
+
+	for _, spec := range specs {
+		files := []pkgFile{{Name: "foo.conf", Path: "etc/foo/foo.conf"}}
+
+		err := applyFileModes(files, []string{spec})
+		if err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestGeneratedFilesAreSortedAfterDeclaredOnes(t *testing.T) {
+	dir := t.TempDir()
+	chdirTest(t, dir)
+
+	for _, name := range []string{"z.conf", "a.conf"} {
+		writeTestFile(t, name, name+"\n", 0644)
+	}
+
+	render := func(generated []pkgFile) (string, []string) {
+		files, err := prepareFileList(
+			getUniqueList([]string{"z.conf", "a.conf", "z.conf"}), "build",
+			getBuildDirFiles("PKGBUILD", ""),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		generatedFilesStart := len(files)
+		files = append(files, generated...)
+		sortFilesByName(files[generatedFilesStart:])
+
+		names := []string{}
+		for _, file := range files {
+			names = append(names, file.Name)
+		}
+
+		return renderPkgbuild(t, pkgData{
+			PkgName: "foo",
+			Files:   files,
+			Sources: []pkgSource{
+				{URL: "https://example.com/b.tar.gz", Hash: "SKIP"},
+				{URL: "https://example.com/a.tar.gz", Hash: "SKIP"},
+			},
+		}), names
+	}
+
+	first, names := render([]pkgFile{
+		{Name: "foo.timer", Path: "usr/lib/systemd/system/foo.timer"},
+		{Name: "foo.service", Path: "usr/lib/systemd/system/foo.service"},
+	})
+
+	expected := []string{"z.conf", "a.conf", "foo.service", "foo.timer"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %q, got %q", expected, names)
+	}
+
+	second, _ := render([]pkgFile{
+		{Name: "foo.service", Path: "usr/lib/systemd/system/foo.service"},
+		{Name: "foo.timer", Path: "usr/lib/systemd/system/foo.timer"},
+	})
+
+	if first != second {
+		t.Fatalf("expected identical output, got:\n%s\nand:\n%s", first, second)
+	}
+
+	assertContains(
+		t, first,
+		"\t\"https://example.com/b.tar.gz\"\n"+
+			"\t\"https://example.com/a.tar.gz\"\n",
+	)
+}
+
+func TestParseDirsRendersInstallDirCommands(t *testing.T) {
+	dirs, err := parseDirs([]string{
+		"var/log/foo:750",
+		"/var/lib/foo/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := renderPkgbuild(t, pkgData{PkgName: "foo", Dirs: dirs})
+
+	assertContains(
+		t, contents,
+		"\tinstall -d -m0750 \"$pkgdir/var/log/foo\"\n"+
+			"\tinstall -d -m0755 \"$pkgdir/var/lib/foo\"\n}\n",
+	)
+}
+
+func TestParseDirsRejectsInvalidDir(t *testing.T) {
+	specs := []string{
+		"../foo:755",
+		"var/log/foo:999",
+		"var/log/$foo:755",
+		":755",
+	}
+
+	for _, spec := range specs {
+		_, err := parseDirs([]string{spec})
+		if err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestPrepareProfileFileInstallsIntoProfileDir(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"foo.sh", "etc/profile.d/foo.sh"},
+		{"foo-env", "etc/profile.d/foo-env.sh"},
+	}
+
+	for _, test := range tests {
+		name := filepath.Join(dir, test.name)
+		writeTestFile(t, name, "export FOO=1\n", 0755)
+
+		profile, err := prepareProfileFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if profile.Path != test.expected || profile.Mode != "0644" ||
+			profile.Name != filepath.Base(test.expected) {
+			t.Errorf("%s: unexpected profile file: %+v", test.name, profile)
+		}
+
+		backup := createBackupList([]pkgFile{profile}, nil, nil)
+		if !reflect.DeepEqual(backup, []string{test.expected}) {
+			t.Errorf(
+				"%s: expected %q in backup, got %q",
+				test.name, test.expected, backup,
+			)
+		}
+	}
+}
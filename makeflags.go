@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var makeflagsRegexp = regexp.MustCompile(
+	`(?m)^\s*(?:export\s+)?MAKEFLAGS=(?:"([^"]*)"|'([^']*)'|(\S*))`,
+)
+
+// getMakepkgConfNames returns makepkg configuration files in order of
+// increasing priority, like makepkg reads them.
+func getMakepkgConfNames(custom string) []string {
+	if custom != "" {
+		return []string{custom}
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+
+	return []string{
+		"/etc/makepkg.conf",
+		filepath.Join(configHome, "pacman", "makepkg.conf"),
+		filepath.Join(os.Getenv("HOME"), ".makepkg.conf"),
+	}
+}
+
+// getMakeflags returns MAKEFLAGS from environment or, if it's not set, from
+// makepkg configuration files, where the last assignment wins.
+func getMakeflags(makepkgConf string) string {
+	if makeflags, ok := os.LookupEnv("MAKEFLAGS"); ok {
+		return makeflags
+	}
+
+	makeflags := ""
+	for _, name := range getMakepkgConfNames(makepkgConf) {
+		contents, err := ioutil.ReadFile(name)
+		if err != nil {
+			continue
+		}
+
+		for _, matches := range makeflagsRegexp.FindAllStringSubmatch(
+			string(contents), -1,
+		) {
+			makeflags = matches[1] + matches[2] + matches[3]
+		}
+	}
+
+	return makeflags
+}
+
+// parseMakeJobs extracts number of jobs from MAKEFLAGS in forms '-j4',
+// '-j 4', '--jobs=4' and '--jobs 4'. Zero is returned if number of jobs is
+// not set or unlimited.
+func parseMakeJobs(makeflags string) int {
+	jobs := 0
+
+	fields := strings.Fields(makeflags)
+	for i, field := range fields {
+		value := ""
+
+		switch {
+		case strings.HasPrefix(field, "--jobs="):
+			value = strings.TrimPrefix(field, "--jobs=")
+
+		case field == "--jobs" || field == "-j":
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+
+		case strings.HasPrefix(field, "-j"):
+			value = strings.TrimPrefix(field, "-j")
+
+		default:
+			continue
+		}
+
+		number, err := strconv.Atoi(value)
+		if err == nil && number > 0 {
+			jobs = number
+		}
+	}
+
+	return jobs
+}
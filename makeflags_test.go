@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMakeJobs(t *testing.T) {
+	tests := []struct {
+		makeflags string
+		expected  int
+	}{
+		{"-j4", 4},
+		{"--jobs=4", 4},
+		{"-j 4", 4},
+		{"--jobs 4", 4},
+		{"-k -j8 -l2", 8},
+		{"-j2 --jobs=6", 6},
+		{"-j", 0},
+		{"-j -k", 0},
+		{"-jfoo", 0},
+		{"-j0", 0},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		jobs := parseMakeJobs(test.makeflags)
+		if jobs != test.expected {
+			t.Errorf("%q: expected %d, got %d", test.makeflags, test.expected, jobs)
+		}
+	}
+}
+
+func TestGetMakeflagsReadsLastAssignment(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "makepkg.conf")
+	writeTestFile(t, name, `#MAKEFLAGS="-j2"
+MAKEFLAGS="-j4"
+export MAKEFLAGS='--jobs=6 -k'
+`, 0644)
+
+	// Setenv restores original value at the end of the test.
+	t.Setenv("MAKEFLAGS", "")
+	os.Unsetenv("MAKEFLAGS")
+
+	if jobs := parseMakeJobs(getMakeflags(name)); jobs != 6 {
+		t.Fatalf("expected 6 jobs from makepkg.conf, got %d", jobs)
+	}
+
+	t.Setenv("MAKEFLAGS", "")
+	if makeflags := getMakeflags(name); makeflags != "" {
+		t.Fatalf("expected environment to win, got %q", makeflags)
+	}
+
+	t.Setenv("MAKEFLAGS", "-j3")
+	if jobs := parseMakeJobs(getMakeflags(name)); jobs != 3 {
+		t.Fatalf("expected 3 jobs from environment, got %d", jobs)
+	}
+}
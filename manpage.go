@@ -0,0 +1,232 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var manPageTemplate = template.Must(
+	template.New("manpage").Parse(`.TH {{.Title}} 1
+.SH NAME
+{{.Name}} \- {{.Summary}}
+.SH SYNOPSIS
+{{- if .Synopsis}}
+.nf
+{{- range .Synopsis}}
+{{.}}
+{{- end}}
+.fi
+{{- else}}
+.B {{.Name}}
+{{- end}}
+.SH DESCRIPTION
+{{- range .Description}}
+.PP
+{{- range .}}
+{{.}}
+{{- end}}
+{{- end}}
+{{- if .Options}}
+.SH OPTIONS
+{{- range .Options}}
+.TP
+.B {{.Name}}
+{{- range .Description}}
+{{.}}
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+var helpUsageRegexp = regexp.MustCompile(`(?i)^usage(\s+of\s+[^:]*)?:\s*(.*)$`)
+
+var helpOptionsRegexp = regexp.MustCompile(`(?i)^([a-z ]*options|flags):$`)
+
+// helpOptionRegexp splits option line into option names with argument and
+// description, separated by at least two spaces or tab.
+var helpOptionRegexp = regexp.MustCompile(`^(-\S*(?: \S+)*?)(?:\s{2,}|\t+)(.*)$`)
+
+type manPageOption struct {
+	Name        string
+	Description []string
+}
+
+// manPageData holds man page sections with contents already escaped for
+// roff, so templates can output them as is.
+type manPageData struct {
+	Title       string
+	Name        string
+	Summary     string
+	Synopsis    []string
+	Description [][]string
+	Options     []manPageOption
+}
+
+// getHelpText returns output of running specified binary with --help.
+// Programs often exit with non-zero code after printing help, so error is
+// returned only if nothing was printed.
+func getHelpText(binary string) (string, error) {
+	output, err := exec.Command(binary, "--help").CombinedOutput()
+	if err != nil && len(strings.TrimSpace(string(output))) == 0 {
+		return "", err
+	}
+
+	return string(output), nil
+}
+
+func readHelpText(name string) (string, error) {
+	contents, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	return string(contents), nil
+}
+
+// parseHelpText splits help text into man page sections. Lines after
+// 'Usage:' up to empty line form synopsis, lines starting with '-' start
+// options, which continue on more indented lines, and everything else goes
+// to description paragraphs.
+func parseHelpText(name string, summary string, text string) manPageData {
+	page := manPageData{
+		Title:   strings.ToUpper(escapeRoff(name)),
+		Name:    escapeRoff(name),
+		Summary: escapeRoff(summary),
+	}
+
+	const (
+		sectionDescription = iota
+		sectionUsage
+		sectionOptions
+	)
+
+	section := sectionDescription
+	paragraph := []string{}
+	optionIndent := 0
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			page.Description = append(page.Description, paragraph)
+			paragraph = []string{}
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		if trimmed == "" {
+			flush()
+
+			if section == sectionUsage {
+				section = sectionDescription
+			}
+
+			continue
+		}
+
+		usage := helpUsageRegexp.FindStringSubmatch(trimmed)
+		if usage != nil {
+			flush()
+
+			section = sectionUsage
+			if usage[2] != "" {
+				page.Synopsis = append(page.Synopsis, escapeRoff(usage[2]))
+			}
+
+			continue
+		}
+
+		if helpOptionsRegexp.MatchString(trimmed) {
+			flush()
+
+			section = sectionOptions
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "-") {
+			flush()
+
+			section = sectionOptions
+			optionIndent = indent
+
+			option := manPageOption{Name: trimmed}
+
+			matches := helpOptionRegexp.FindStringSubmatch(trimmed)
+			if matches != nil {
+				option.Name = matches[1]
+				option.Description = []string{escapeRoff(matches[2])}
+			}
+
+			option.Name = strings.Replace(
+				escapeRoff(option.Name), "-", `\-`, -1,
+			)
+
+			page.Options = append(page.Options, option)
+
+			continue
+		}
+
+		switch {
+		case section == sectionUsage:
+			page.Synopsis = append(page.Synopsis, escapeRoff(trimmed))
+
+		case section == sectionOptions && len(page.Options) > 0 &&
+			indent > optionIndent:
+			option := &page.Options[len(page.Options)-1]
+			option.Description = append(
+				option.Description, escapeRoff(trimmed),
+			)
+
+		default:
+			section = sectionDescription
+			paragraph = append(paragraph, escapeRoff(trimmed))
+		}
+	}
+
+	flush()
+
+	if len(page.Description) == 0 {
+		page.Description = [][]string{{page.Summary}}
+	}
+
+	return page
+}
+
+// escapeRoff escapes backslashes and leading control characters, so line is
+// output literally by roff.
+func escapeRoff(line string) string {
+	line = strings.Replace(line, `\`, `\e`, -1)
+	if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+		line = `\&` + line
+	}
+
+	return line
+}
+
+func createManPage(output io.Writer, data manPageData) error {
+	logStep("Creating man page...")
+	return manPageTemplate.Execute(output, data)
+}
+
+// getBuiltBinary returns path to binary installed into package directory by
+// previous makepkg run, or empty string if there is no such binary.
+func getBuiltBinary(
+	dir string, pkgName string, binDir string, execName string,
+) string {
+	binary := filepath.Join(dir, "pkg", pkgName, binDir, execName)
+
+	info, err := os.Stat(binary)
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+
+	return binary
+}
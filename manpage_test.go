@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleHelpText = `foo finds files fast.
+
+It walks directories in parallel.
+
+Usage:
+  foo [options] <pattern>
+  foo -h | --help
+
+Options:
+  -d --dir <DIR>  Search in specified directory
+                  instead of current one.
+  -x              Use \d for digits.
+  --version       Show version.
+`
+
+func TestParseHelpTextSplitsSections(t *testing.T) {
+	page := parseHelpText("foo", "find files fast", sampleHelpText)
+
+	expected := manPageData{
+		Title:   "FOO",
+		Name:    "foo",
+		Summary: "find files fast",
+		Synopsis: []string{
+			"foo [options] <pattern>",
+			"foo -h | --help",
+		},
+		Description: [][]string{
+			{"foo finds files fast."},
+			{"It walks directories in parallel."},
+		},
+		Options: []manPageOption{
+			{
+				`\-d \-\-dir <DIR>`,
+				[]string{
+					"Search in specified directory",
+					"instead of current one.",
+				},
+			},
+			{`\-x`, []string{`Use \ed for digits.`}},
+			{`\-\-version`, []string{"Show version."}},
+		},
+	}
+
+	if !reflect.DeepEqual(page, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, page)
+	}
+}
+
+func TestCreateManPageRendersSections(t *testing.T) {
+	contents := &strings.Builder{}
+
+	err := createManPage(
+		contents, parseHelpText("foo", "find files fast", sampleHelpText),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContains(
+		t, contents.String(),
+		".TH FOO 1\n.SH NAME\nfoo \\- find files fast\n",
+		".SH SYNOPSIS\n.nf\nfoo [options] <pattern>\nfoo -h | --help\n.fi\n",
+		".SH DESCRIPTION\n.PP\nfoo finds files fast.\n.PP\n",
+		".SH OPTIONS\n.TP\n.B \\-d \\-\\-dir <DIR>\n",
+		".TP\n.B \\-\\-version\nShow version.\n",
+	)
+
+	sections := []string{}
+	for _, line := range strings.Split(contents.String(), "\n") {
+		if strings.HasPrefix(line, ".SH ") {
+			sections = append(sections, line)
+		}
+	}
+
+	expected := []string{
+		".SH NAME", ".SH SYNOPSIS", ".SH DESCRIPTION", ".SH OPTIONS",
+	}
+	if !reflect.DeepEqual(sections, expected) {
+		t.Fatalf("expected sections %q, got %q", expected, sections)
+	}
+}
+
+func TestParseHelpTextWithoutDescription(t *testing.T) {
+	page := parseHelpText("foo", "find files fast", "Usage: foo <pattern>\n")
+
+	if !reflect.DeepEqual(page.Synopsis, []string{"foo <pattern>"}) {
+		t.Fatalf("unexpected synopsis: %q", page.Synopsis)
+	}
+
+	expected := [][]string{{"find files fast"}}
+	if !reflect.DeepEqual(page.Description, expected) {
+		t.Fatalf("expected %q, got %q", expected, page.Description)
+	}
+}
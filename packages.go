@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// findMainPackageDirs returns directories of local source checkout, relative
+// to it, which contain main package. Vendored code, test data and hidden
+// directories are skipped, as well as files excluded by build constraints,
+// like generators marked with '//go:build ignore'.
+func findMainPackageDirs(root string) ([]string, error) {
+	dirs := map[string]bool{}
+
+	err := filepath.Walk(
+		root,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				name := info.Name()
+				if path != root && (name == "vendor" || name == "testdata" ||
+					strings.HasPrefix(name, ".") ||
+					strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if filepath.Ext(path) != ".go" ||
+				strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+
+			isMatched, err := build.Default.MatchFile(
+				filepath.Dir(path), info.Name(),
+			)
+			if err != nil {
+				return err
+			}
+
+			if !isMatched {
+				return nil
+			}
+
+			file, err := parser.ParseFile(
+				token.NewFileSet(), path, nil, parser.PackageClauseOnly,
+			)
+			if err != nil {
+				return err
+			}
+
+			if file.Name.Name == "main" {
+				dir, err := filepath.Rel(root, filepath.Dir(path))
+				if err != nil {
+					return err
+				}
+
+				dirs[filepath.ToSlash(dir)] = true
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for dir := range dirs {
+		result = append(result, dir)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// checkSingleBinary ensures that exactly one binary is built: either main
+// package is found in selected command directory or it's the only one.
+func checkSingleBinary(mainDirs []string, cmdDir string) error {
+	switch {
+	case cmdDir != "" && !isStringInList(cmdDir, mainDirs):
+		return fmt.Errorf(
+			"no main package found in command directory %q", cmdDir,
+		)
+
+	case cmdDir == "" && len(mainDirs) > 1:
+		return fmt.Errorf(
+			"several main packages found: %s; "+
+				"use --cmd-dir to select one or add '...' suffix to "+
+				"repo URL to build all of them",
+			strings.Join(mainDirs, ", "),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeMultiMainRepo creates checkout with two commands, library package with
+// ignored generator and ignored main packages in vendor and testdata.
+func writeMultiMainRepo(t *testing.T) string {
+	dir := t.TempDir()
+
+	for name, contents := range map[string]string{
+		"cmd/server/main.go":       "package main\n",
+		"cmd/client/main.go":       "package main\n",
+		"cmd/client/main_test.go":  "package main_test\n",
+		"lib/lib.go":               "package lib\n",
+		"lib/gen.go":               "//go:build ignore\n\npackage main\n",
+		"vendor/x/tool/main.go":    "package main\n",
+		"testdata/example/main.go": "package main\n",
+		".hidden/main.go":          "package main\n",
+	} {
+		writeTestFile(t, filepath.Join(dir, name), contents, 0644)
+	}
+
+	return dir
+}
+
+func TestCheckSingleBinaryFailsOnMultipleMainPackages(t *testing.T) {
+	mainDirs, err := findMainPackageDirs(writeMultiMainRepo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"cmd/client", "cmd/server"}
+	if !reflect.DeepEqual(mainDirs, expected) {
+		t.Fatalf("expected %q, got %q", expected, mainDirs)
+	}
+
+	err = checkSingleBinary(mainDirs, "")
+	if err == nil {
+		t.Fatal("expected error for multiple main packages")
+	}
+
+	err = checkSingleBinary(mainDirs, "cmd/server")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = checkSingleBinary(mainDirs, "lib")
+	if err == nil {
+		t.Fatal("expected error for directory without main package")
+	}
+}
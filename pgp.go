@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var pgpFingerprintRegexp = regexp.MustCompile(`^[0-9A-F]{40}$`)
+
+// parsePGPKeys normalizes specified key fingerprints to the form accepted by
+// makepkg in validpgpkeys: 40 upper-case hex digits without spaces.
+func parsePGPKeys(rawKeys []string) ([]string, error) {
+	keys := []string{}
+	for _, rawKey := range rawKeys {
+		key := strings.ToUpper(
+			strings.Replace(strings.TrimPrefix(rawKey, "0x"), " ", "", -1),
+		)
+
+		if !pgpFingerprintRegexp.MatchString(key) {
+			return nil, fmt.Errorf(
+				"invalid PGP key: %q, expected full 40 characters fingerprint",
+				rawKey,
+			)
+		}
+
+		if !isStringInList(key, keys) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// getPGPImportCommands returns gpg commands which import specified keys from
+// keyserver, one command per key, so failure to fetch one is reported
+// separately.
+func getPGPImportCommands(keys []string) [][]string {
+	commands := [][]string{}
+	for _, key := range keys {
+		commands = append(commands, []string{"gpg", "--recv-keys", key})
+	}
+
+	return commands
+}
+
+// importPGPKeys imports specified keys into user keyring, so makepkg can
+// verify signed sources.
+func importPGPKeys(keys []string) error {
+	logStep("Importing PGP keys...")
+
+	timeout := getTimeout(preflightTimeout)
+
+	for _, command := range getPGPImportCommands(keys) {
+		logSubStep("Importing key: %s", command[len(command)-1])
+
+		ctx, cancel := newTimeoutContext(timeout)
+
+		cmd := execCommandContext(ctx, command[0], command[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err := cmd.Run()
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf(
+				"can't import PGP key %s: %s",
+				command[len(command)-1], getTimeoutError(ctx, timeout, err),
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	testPGPKey1 = "0123456789ABCDEF0123456789ABCDEF01234567"
+	testPGPKey2 = "89ABCDEF0123456789ABCDEF0123456789ABCDEF"
+)
+
+func TestParsePGPKeysNormalizesFingerprints(t *testing.T) {
+	keys, err := parsePGPKeys([]string{
+		"0x0123456789abcdef0123456789abcdef01234567",
+		"89AB CDEF 0123 4567 89AB CDEF 0123 4567 89AB CDEF",
+		testPGPKey1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{testPGPKey1, testPGPKey2}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected %q, got %q", expected, keys)
+	}
+
+	_, err = parsePGPKeys([]string{"01234567"})
+	if err == nil {
+		t.Fatal("expected error for short key id")
+	}
+}
+
+func TestImportPGPKeysRunsGPGForEveryKey(t *testing.T) {
+	commands := fakeCommands(t, "exit 0")
+
+	err := importPGPKeys([]string{testPGPKey1, testPGPKey2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"gpg", "--recv-keys", testPGPKey1},
+		{"gpg", "--recv-keys", testPGPKey2},
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, *commands)
+	}
+}
+
+func TestImportPGPKeysStopsOnFailure(t *testing.T) {
+	commands := fakeCommands(t, "exit 2")
+
+	err := importPGPKeys([]string{testPGPKey1, testPGPKey2})
+	if err == nil {
+		t.Fatal("expected error for failed import")
+	}
+
+	if len(*commands) != 1 {
+		t.Fatalf("expected single gpg run, got %q", *commands)
+	}
+}
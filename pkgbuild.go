@@ -5,82 +5,185 @@ import "text/template"
 var pkgbuildTemplate = template.Must(
 	template.New("pkgbuild").Parse(
 		`{{if ne .Maintainer ""}}# Maintainer: {{.Maintainer}}
-{{end}}pkgname={{.PkgName}}
-_pkgname={{.ProgramName}}
-pkgver=${PKGVER:-autogenerated}
+{{end}}{{range .CoMaintainers}}# Maintainer: {{.}}
+{{end}}{{if and .PkgBase (ne .PkgBase .PkgName)}}pkgbase={{.PkgBase}}
+{{end}}pkgname={{.PkgName}}{{if not .IsMeta}}
+_pkgname={{.ProgramName}}{{end}}
+pkgver={{if .PkgVer}}{{.PkgVer}}{{if .IsPkgVerPlaceholder}} # TODO: set version{{end}}{{else}}${PKGVER:-autogenerated}{{end}}
 pkgrel={{if eq .PkgRel "1"}}${PKGREL:-1}{{else}}{{.PkgRel}}{{end}}
 pkgdesc="{{.PkgDesc}}"
-arch=('i686' 'x86_64')
-license=('{{.License}}')
+arch=({{range $i, $arch := .Arch}}{{if $i}} {{end}}'{{$arch}}'{{end}})
+license=({{range $i, $license := .Licenses}}{{if $i}} {{end}}'{{$license}}'{{end}})
 depends=({{range .Dependencies}}
 	'{{.}}'{{end}}
 )
-makedepends=(
+makedepends=({{if not (or .IsMeta .IsBinRelease)}}
 	'go'
-	'git'{{range .MakeDependencies}}
+	'git'{{if .UseMake}}
+	'make'{{end}}{{end}}{{range .MakeDependencies}}
 	'{{.}}'{{end}}
 )
-
-source=(
-	"$_pkgname::git+{{.RepoURL}}#branch=${BRANCH:-master}"{{range .Files}}
-	"{{.Name}}"{{end}}
+{{if .Provides}}provides=({{range .Provides}}
+	'{{.}}'{{end}}
+)
+{{end}}{{if .Conflicts}}conflicts=({{range .Conflicts}}
+	'{{.}}'{{end}}
+)
+{{end}}{{if .ExtraVars}}{{range .ExtraVars}}
+{{.Name}}={{.Value}}{{end}}
+{{end}}{{if .DLAgents}}
+DLAGENTS=({{range .DLAgents}}
+	"{{.}}"{{end}}
+	"${DLAGENTS[@]}"
+)
+{{end}}
+{{if .IsShallow}}# makepkg clones full history of git sources and can't make shallow clones{{if or (eq .RefKind "tag") (eq .RefKind "commit")}},
+# so source is pinned to {{.RefKind}} to build fixed revision instead{{end}}.
+{{end}}source=({{if not (or .IsMeta .IsBinRelease)}}
+	"{{.SourceDir}}::git+{{.RepoURL}}#{{if .RefKind}}{{.RefKind}}={{.RefName}}{{else}}branch=${BRANCH:-{{.DefaultBranch}}}{{end}}"{{end}}{{range .Files}}
+	"{{.Name}}"{{end}}{{range .Patches}}
+	"{{.Name}}"{{end}}{{range .Sources}}
+	"{{.URL}}"{{end}}
 )
 
-md5sums=(
-	'SKIP'{{range .Files}}
+md5sums=({{if not (or .IsMeta .IsBinRelease)}}
+	'SKIP'{{end}}{{range .Files}}
+	'{{.Hash}}'{{end}}{{range .Patches}}
+	'{{.Hash}}'{{end}}{{range .Sources}}
 	'{{.Hash}}'{{end}}
 )
+{{range .ArchSources}}
+source_{{.Arch}}=({{range .Sources}}
+	"{{.URL}}"{{end}}
+)
 
+md5sums_{{.Arch}}=({{range .Sources}}
+	'{{.Hash}}'{{end}}
+)
+{{end}}{{if .ValidPGPKeys}}
+# Keys should be imported before build, e.g. with 'gpg --recv-keys <KEY>'.
+validpgpkeys=({{range .ValidPGPKeys}}
+	'{{.}}'{{end}}
+)
+{{end}}{{if .NoExtract}}
+noextract=({{range .NoExtract}}
+	"{{.}}"{{end}}
+)
+{{end}}
 backup=({{range .Backup}}
 	"{{.}}"{{end}}
 )
-
+{{if .InstallScript}}
+install={{.InstallScript}}
+{{end}}{{if .IsDebugPackage}}
+options=('debug' '!strip')
+{{end}}{{if .IsSplitDebug}}
+# Debug symbols are split by makepkg into {{or .PkgBase .PkgName}}-debug
+# companion package. Go linker compresses them by default, which makepkg
+# can't handle, so compression is disabled in ldflags. Binaries must not be
+# stripped with '-ldflags=-s -w', otherwise there are no symbols to split.
+options=('debug' 'strip')
+{{end}}{{if not (or .IsMeta .IsBinRelease)}}{{if not .PkgVer}}
 pkgver() {
 	if [[ "$PKGVER" ]]; then
 		echo "$PKGVER"
 		return
 	fi
 
-	cd "$srcdir/$_pkgname"
+	cd "$srcdir/{{.SourceDir}}"{{if eq .RefKind "tag"}}
+	git describe --tags | sed '{{.PkgVerSed}}'{{else}}
 	local date=$(git log -1 --format="%cd" --date=short | sed s/-//g)
 	local count=$(git rev-list --count HEAD)
 	local commit=$(git rev-parse --short HEAD)
-	echo "$date.${count}_$commit"
+	echo "$date.${count}_$commit"{{end}}
 }
-
+{{end}}{{if .Patches}}
+prepare() {
+	cd "$srcdir/{{.SourceDir}}"
+{{range .Patches}}
+	patch -Np{{$.PatchStrip}} < "$srcdir/{{.Name}}"{{end}}
+}
+{{end}}{{if .GoDependencies}}
+# Go module dependencies:{{range .GoDependencies}}
+#   {{.}}{{end}}
+{{end}}
 build() {
-	cd "$srcdir/$_pkgname"
+	cd "$srcdir/{{.SourceDir}}"
 
-	if [ -L "$srcdir/$_pkgname" ]; then
-		rm "$srcdir/$_pkgname" -rf
-		mv "$srcdir/go/src/$_pkgname/" "$srcdir/$_pkgname"
+	if [ -L "$srcdir/{{.SourceDir}}" ]; then
+		rm "$srcdir/{{.SourceDir}}" -rf
+		mv "$srcdir/go/src/{{.GoSrcDir}}/" "$srcdir/{{.SourceDir}}"
 	fi
 
 	rm -rf "$srcdir/go/src"
 
-	mkdir -p "$srcdir/go/src"
-
-	export GOPATH="$srcdir/go"
+	mkdir -p "$(dirname "$srcdir/go/src/{{.GoSrcDir}}")"
+{{template "goenv" .}}
 
-	mv "$srcdir/$_pkgname" "$srcdir/go/src/"
+	mv "$srcdir/{{.SourceDir}}" "$srcdir/go/src/{{.GoSrcDir}}"
 
-	cd "$srcdir/go/src/$_pkgname/"
-	ln -sf "$srcdir/go/src/$_pkgname/" "$srcdir/$_pkgname"
+	cd "$srcdir/go/src/{{.GoSrcDir}}/"
+	ln -sf "$srcdir/go/src/{{.GoSrcDir}}/" "$srcdir/{{.SourceDir}}"
 
 	echo ":: Updating git submodules"
 	git submodule update --init
+{{if not .IsGoInstall}}{{if .IsReproducible}}
+	export SOURCE_DATE_EPOCH=$(git log -1 --format=%ct)
+{{end}}
+	echo ":: Building binary"{{if .UseMake}}
+	make{{if .MakeTarget}} {{.MakeTarget}}{{end}}{{else if .WorkspaceModule}}
+	cd "{{.WorkspaceModule}}"
+	export GOWORK="$srcdir/go/src/{{.GoSrcDir}}/go.work"
+	export GOFLAGS="{{.GoFlags}}"
+
+	go build {{template "goflags" .}}{{else if .MainFile}}
+	if [ ! -f go.mod ]; then
+		export GO111MODULE=off
+	fi
 
-	echo ":: Building binary"
-	go get -v \
-		-gcflags "-trimpath $GOPATH/src"{{if ne .VersionVarName ""}} \
-		-ldflags="-X main.{{.VersionVarName}}=$pkgver-$pkgrel"{{end}}{{if .IsWildcardBuild}} \
-		./...{{end}}
+	go build {{template "goflags" .}}{{else}}
+	go get {{template "goflags" .}}{{end}}
+{{end -}}
 }
-
+{{end}}
 package() {
+{{- if .IsMeta}}
+	:
+{{- else if .IsGoInstall}}
+	cd "$srcdir/go/src/{{.GoSrcDir}}/"
+{{template "goenv" .}}{{if .IsReproducible}}
+	export SOURCE_DATE_EPOCH=$(git log -1 --format=%ct){{end}}
+
+	echo ":: Installing binary"
+	GOBIN="$pkgdir/{{.BinDir}}" go install {{template "goflags" .}}
+{{- else if .UseMake}}
+	cd "$srcdir/{{.SourceDir}}"
+	make DESTDIR="$pkgdir" install
+{{- else}}{{range .BinaryInstalls}}
+	{{.}}{{else}}
 	find "$srcdir/go/bin/" -type f -executable | while read filename; do
-		install -DT "$filename" "$pkgdir/usr/bin/$(basename $filename)"
-	done{{range .Files}}
-	install -DT -m0755 "$srcdir/{{.Name}}" "$pkgdir/{{.Path}}"{{end}}
+		install -Dm755 "$filename" "$pkgdir/{{.BinDir}}/$(basename $filename)"
+	done{{end}}
+{{- end}}{{range .Files}}
+	install -DT -m{{or .Mode "0755"}} "$srcdir/{{.Name}}" "$pkgdir/{{.Path}}"{{end}}{{range .Dirs}}
+	install -d -m{{.Mode}} "$pkgdir/{{.Path}}"{{end}}
 }
+{{- define "goenv"}}
+	export GOPATH="$srcdir/go"{{if .GoProxy}}
+	export GOPROXY="{{.GoProxy}}"{{end}}{{if .GoSumDB}}
+	export GOSUMDB="{{.GoSumDB}}"{{end}}{{if .BuildJobs}}
+	export GOMAXPROCS={{.BuildJobs}}{{end}}
+{{- end}}
+{{- define "goflags"}}-v \{{if .BuildJobs}}
+		-p {{.BuildJobs}} \{{end}}{{if .IsReproducible}}
+		-trimpath \{{end}}
+		-gcflags "{{if .IsDebugPackage}}all=-N -l {{end}}-trimpath $GOPATH/src"{{if or .VersionVarName .IsSplitDebug}} \
+		-ldflags="{{if .IsSplitDebug}}-compressdwarf=false{{if .VersionVarName}} {{end}}{{end}}{{if .VersionVarName}}-X main.{{.VersionVarName}}=$pkgver-$pkgrel{{end}}"{{end}}{{if .MainFile}} \
+		-o "$GOPATH/bin/{{.BuiltBinaryName}}" \
+		{{.MainFile}}{{else if .WorkspaceModule}} \
+		-o "$GOPATH/bin/{{.BuiltBinaryName}}" \
+		./{{.CmdDir}}{{else if .IsWildcardBuild}} \
+		./...{{else if .CmdDir}} \
+		./{{.CmdDir}}{{end}}
+{{- end}}
 `))
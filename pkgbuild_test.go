@@ -0,0 +1,746 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// renderPkgbuild renders PKGBUILD for specified data.
+func renderPkgbuild(t *testing.T, data pkgData) string {
+	t.Helper()
+
+	contents := &strings.Builder{}
+	err := createPkgbuild(contents, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return contents.String()
+}
+
+// assertContains fails test if contents don't contain every of specified
+// snippets.
+func assertContains(t *testing.T, contents string, snippets ...string) {
+	t.Helper()
+
+	for _, snippet := range snippets {
+		if !strings.Contains(contents, snippet) {
+			t.Fatalf("expected %q in:\n%s", snippet, contents)
+		}
+	}
+}
+
+func TestPkgbuildRendersArchSpecificSums(t *testing.T) {
+	archSources, err := parseArchSources([]string{
+		"x86_64:https://example.com/foo-amd64.tar.gz",
+		"aarch64:https://example.com/foo-arm64.tar.gz",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archSources[0].Sources[0].Hash = "1111"
+	archSources[1].Sources[0].Hash = "2222"
+
+	contents := renderPkgbuild(t, pkgData{
+		IsBinRelease: true,
+		Sources: []pkgSource{
+			{URL: "https://example.com/foo.conf", Hash: "0000"},
+		},
+		ArchSources: archSources,
+	})
+
+	assertContains(
+		t, contents,
+		"source=(\n\t\"https://example.com/foo.conf\"\n)\n",
+		"md5sums=(\n\t'0000'\n)\n",
+		"source_x86_64=(\n\t\"https://example.com/foo-amd64.tar.gz\"\n)\n",
+		"md5sums_x86_64=(\n\t'1111'\n)\n",
+		"source_aarch64=(\n\t\"https://example.com/foo-arm64.tar.gz\"\n)\n",
+		"md5sums_aarch64=(\n\t'2222'\n)\n",
+	)
+}
+
+func TestPkgbuildRendersNoExtractOnlyWhenSet(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		NoExtract: []string{"blob.tar.gz", "font.zip"},
+	})
+
+	assertContains(
+		t, contents,
+		"noextract=(\n\t\"blob.tar.gz\"\n\t\"font.zip\"\n)\n",
+	)
+
+	contents = renderPkgbuild(t, pkgData{})
+	if strings.Contains(contents, "noextract=") {
+		t.Fatalf("expected no noextract array in:\n%s", contents)
+	}
+}
+
+func TestPkgbuildAppliesPatchesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		filepath.Join(dir, "02-fix.patch"),
+		filepath.Join(dir, "01-feature.patch"),
+	}
+
+	for _, name := range names {
+		writeTestFile(t, name, "--- a\n+++ b\n", 0644)
+	}
+
+	patches, err := preparePatchList(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir:  "foo",
+		Patches:    patches,
+		PatchStrip: "2",
+	})
+
+	assertContains(
+		t, contents,
+		"\t\"02-fix.patch\"\n\t\"01-feature.patch\"\n",
+		"prepare() {\n"+
+			"\tcd \"$srcdir/foo\"\n\n"+
+			"\tpatch -Np2 < \"$srcdir/02-fix.patch\"\n"+
+			"\tpatch -Np2 < \"$srcdir/01-feature.patch\"\n"+
+			"}\n",
+	)
+}
+
+func TestPkgbuildOmitsPrepareWithoutPatches(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{SourceDir: "foo"})
+	if strings.Contains(contents, "prepare()") {
+		t.Fatalf("expected no prepare() in:\n%s", contents)
+	}
+}
+
+func TestPkgbuildRendersDebugPackageOptions(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		IsDebugPackage: true,
+		MainFile:       "main.go",
+	})
+
+	assertContains(
+		t, contents,
+		"options=('debug' '!strip')\n",
+		`-gcflags "all=-N -l -trimpath $GOPATH/src"`,
+	)
+}
+
+func TestPkgbuildOmitsDebugOptionsByDefault(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{MainFile: "main.go"})
+
+	if strings.Contains(contents, "options=") ||
+		strings.Contains(contents, "all=-N -l") {
+		t.Fatalf("expected no debug options in:\n%s", contents)
+	}
+}
+
+func TestPkgbuildRendersSplitDebugCompanionPackage(t *testing.T) {
+	tests := []struct {
+		data      pkgData
+		companion string
+		ldflags   string
+	}{
+		{
+			pkgData{PkgName: "foo", IsSplitDebug: true},
+			"foo-debug",
+			`-ldflags="-compressdwarf=false"`,
+		},
+		{
+			pkgData{
+				PkgName:        "foo-git",
+				PkgBase:        "foo",
+				VersionVarName: "version",
+				IsSplitDebug:   true,
+			},
+			"foo-debug",
+			`-ldflags="-compressdwarf=false -X main.version=$pkgver-$pkgrel"`,
+		},
+	}
+
+	for _, test := range tests {
+		contents := renderPkgbuild(t, test.data)
+
+		assertContains(
+			t, contents,
+			"# Debug symbols are split by makepkg into "+test.companion+"\n",
+			"options=('debug' 'strip')\n",
+			test.ldflags,
+		)
+
+		if strings.Contains(contents, "!strip") ||
+			strings.Contains(contents, "all=-N -l") {
+			t.Errorf("expected no debug package options in:\n%s", contents)
+		}
+	}
+}
+
+func TestPkgbuildRendersExtraVarsInOrder(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		ExtraVars: []pkgVar{
+			{Name: "_modname", Value: "foo"},
+			{Name: "_commit", Value: "abc"},
+		},
+	})
+
+	assertContains(t, contents, "\n_modname=foo\n_commit=abc\n")
+}
+
+func TestPkgbuildRendersRefFragments(t *testing.T) {
+	tests := []struct {
+		ref      string
+		fragment string
+	}{
+		{"", "#branch=${BRANCH:-master}\""},
+		{"branch=devel", "#branch=devel\""},
+		{"tag=v1.0.0", "#tag=v1.0.0\""},
+		{"commit=0123abc", "#commit=0123abc\""},
+	}
+
+	for _, test := range tests {
+		refKind, refName, err := parseRef(test.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents := renderPkgbuild(t, pkgData{
+			SourceDir:     "foo",
+			RepoURL:       "https://github.com/user/foo",
+			RefKind:       refKind,
+			RefName:       refName,
+			DefaultBranch: "master",
+		})
+
+		assertContains(
+			t, contents,
+			"\"foo::git+https://github.com/user/foo"+test.fragment,
+		)
+	}
+}
+
+func TestPkgbuildDescribesTagInPkgver(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir: "foo",
+		RefKind:   "tag",
+		RefName:   "v1.0.0",
+		PkgVerSed: "s/^v//",
+	})
+
+	assertContains(t, contents, "git describe --tags | sed 's/^v//'")
+}
+
+func TestPkgbuildUsesCustomPkgVerSed(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir: "foo",
+		RefKind:   "tag",
+		RefName:   "release-1-0",
+		PkgVerSed: "s/^release-//;s/-/./g",
+	})
+
+	assertContains(
+		t, contents,
+		"pkgver() {\n",
+		"\tcd \"$srcdir/foo\"\n"+
+			"\tgit describe --tags | sed 's/^release-//;s/-/./g'\n}\n",
+	)
+}
+
+func TestPkgbuildInstallsEveryLicenseFile(t *testing.T) {
+	dir := t.TempDir()
+
+	files := []pkgFile{}
+	for _, name := range []string{"LICENSE-MIT", "LICENSE-APACHE"} {
+		writeTestFile(t, filepath.Join(dir, name), name, 0644)
+
+		file, err := prepareLicenseFile(
+			filepath.Join(dir, name), "usr/share/licenses/foo",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, file)
+	}
+
+	contents := renderPkgbuild(t, pkgData{
+		Licenses: []string{"MIT", "Apache-2.0"},
+		Files:    files,
+	})
+
+	assertContains(
+		t, contents,
+		"license=('MIT' 'Apache-2.0')\n",
+		`install -DT -m0755 "$srcdir/LICENSE-MIT" `+
+			`"$pkgdir/usr/share/licenses/foo/LICENSE-MIT"`,
+		`install -DT -m0755 "$srcdir/LICENSE-APACHE" `+
+			`"$pkgdir/usr/share/licenses/foo/LICENSE-APACHE"`,
+	)
+}
+
+func TestPkgbuildForMetaPackageHasNoBuild(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:      "foo-meta",
+		IsMeta:       true,
+		Arch:         getArchList([]string{"x86_64"}, nil, true),
+		Dependencies: []string{"foo", "bar"},
+	})
+
+	assertContains(
+		t, contents,
+		"arch=('any')\n",
+		"depends=(\n\t'foo'\n\t'bar'\n)\n",
+		"source=(\n)\n",
+		"package() {\n\t:\n}\n",
+	)
+
+	for _, snippet := range []string{"build()", "pkgver()", "install -D"} {
+		if strings.Contains(contents, snippet) {
+			t.Fatalf("expected no %q in:\n%s", snippet, contents)
+		}
+	}
+}
+
+func TestPkgbuildRendersGoEnvOnlyWhenSet(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir: "foo",
+		GoProxy:   "https://proxy.example.com",
+		GoSumDB:   "off",
+	})
+
+	assertContains(
+		t, contents,
+		"\texport GOPROXY=\"https://proxy.example.com\"\n",
+		"\texport GOSUMDB=\"off\"\n",
+	)
+
+	contents = renderPkgbuild(t, pkgData{SourceDir: "foo"})
+	if strings.Contains(contents, "GOPROXY") ||
+		strings.Contains(contents, "GOSUMDB") {
+		t.Fatalf("expected no GOPROXY and GOSUMDB in:\n%s", contents)
+	}
+}
+
+func TestPkgbuildRendersReproducibleBuild(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir:      "foo",
+		MainFile:       "main.go",
+		IsReproducible: true,
+	})
+
+	assertContains(
+		t, contents,
+		"\texport SOURCE_DATE_EPOCH=$(git log -1 --format=%ct)\n\n"+
+			"\techo \":: Building binary\"",
+		"\t\t-trimpath \\\n",
+	)
+
+	contents = renderPkgbuild(t, pkgData{SourceDir: "foo", MainFile: "main.go"})
+	if strings.Contains(contents, "SOURCE_DATE_EPOCH") ||
+		strings.Contains(contents, "\t-trimpath") {
+		t.Fatalf("expected no reproducible build settings in:\n%s", contents)
+	}
+}
+
+func TestPkgbuildRendersPkgbaseOnlyWhenDiffers(t *testing.T) {
+	tests := []struct {
+		pkgBase  string
+		expected bool
+	}{
+		{"", false},
+		{"foo", false},
+		{"foo-base", true},
+	}
+
+	for _, test := range tests {
+		contents := renderPkgbuild(t, pkgData{
+			PkgName: "foo",
+			PkgBase: test.pkgBase,
+		})
+
+		if strings.Contains(contents, "pkgbase=") != test.expected {
+			t.Errorf(
+				"%q: expected pkgbase rendered: %t, got:\n%s",
+				test.pkgBase, test.expected, contents,
+			)
+		}
+	}
+
+	contents := renderPkgbuild(t, pkgData{PkgName: "foo", PkgBase: "foo-base"})
+	assertContains(t, contents, "pkgbase=foo-base\npkgname=foo\n")
+}
+
+func TestCreateSnippedPkgbuildBracketsGeneratedBody(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "head"), "_build_flags=-tags=x", 0644)
+	writeTestFile(t, filepath.Join(dir, "tail"), "export FOO=bar\n", 0644)
+
+	head, err := readSnippet(filepath.Join(dir, "head"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tail, err := readSnippet(filepath.Join(dir, "tail"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := &strings.Builder{}
+	err = createSnippedPkgbuild(contents, pkgData{PkgName: "foo"}, head, tail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(
+		contents.String(), "_build_flags=-tags=x\n"+generatedMarker,
+	) {
+		t.Fatalf("expected prepended snippet, got:\n%s", contents)
+	}
+
+	if !strings.HasSuffix(contents.String(), "}\nexport FOO=bar\n") {
+		t.Fatalf("expected appended snippet, got:\n%s", contents)
+	}
+}
+
+func TestReadSnippetRejectsMissingFile(t *testing.T) {
+	_, err := readSnippet(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatal("expected error for missing snippet")
+	}
+}
+
+func TestPkgbuildBuildsCommandDir(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir:       "foo",
+		GoSrcDir:        "github.com/user/foo",
+		CmdDir:          "cmd/tool",
+		BuiltBinaryName: "tool",
+		BinDir:          "usr/bin",
+		BinaryInstalls: []string{
+			getBinaryInstall("$srcdir/go/bin/tool", "usr/bin", "tool"),
+		},
+	})
+
+	assertContains(
+		t, contents,
+		"\tgo get -v \\\n",
+		"-trimpath $GOPATH/src\" \\\n\t\t./cmd/tool\n",
+		`install -Dm755 "$srcdir/go/bin/tool" "$pkgdir/usr/bin/tool"`,
+	)
+}
+
+func TestPkgbuildRendersRenamedSources(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir:     "foo-src",
+		RepoURL:       "https://github.com/user/foo",
+		DefaultBranch: "master",
+		Sources: []pkgSource{
+			{URL: "foo-data.tar.gz::https://example.com/data.tar.gz"},
+		},
+	})
+
+	assertContains(
+		t, contents,
+		"\t\"foo-src::git+https://github.com/user/foo#branch=",
+		"\t\"foo-data.tar.gz::https://example.com/data.tar.gz\"\n",
+		"pkgver() {\n\tif [[ \"$PKGVER\" ]]; then\n\t\techo \"$PKGVER\"\n"+
+			"\t\treturn\n\tfi\n\n\tcd \"$srcdir/foo-src\"\n",
+	)
+}
+
+func TestPkgbuildForBinReleaseHasNoBuild(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:      "foo-bin",
+		ProgramName:  "foo",
+		PkgVer:       "1.0.0",
+		IsBinRelease: true,
+		Sources: []pkgSource{{
+			URL:  "https://example.com/foo-linux-amd64.tar.gz",
+			Hash: "0123",
+		}},
+		BinaryInstalls: []string{
+			getBinaryInstall("$srcdir/foo", "usr/bin", "foo"),
+		},
+	})
+
+	assertContains(
+		t, contents,
+		"makedepends=(\n)\n",
+		"source=(\n\t\"https://example.com/foo-linux-amd64.tar.gz\"\n)\n",
+		"md5sums=(\n\t'0123'\n)\n",
+		"package() {\n"+
+			"\tinstall -Dm755 \"$srcdir/foo\" \"$pkgdir/usr/bin/foo\"\n}\n",
+	)
+
+	for _, snippet := range []string{"build()", "pkgver()", "git+"} {
+		if strings.Contains(contents, snippet) {
+			t.Fatalf("expected no %q in:\n%s", snippet, contents)
+		}
+	}
+}
+
+func TestPkgbuildRendersBuildJobsOnlyWhenSet(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		SourceDir: "foo",
+		MainFile:  "main.go",
+		BuildJobs: "4",
+	})
+
+	assertContains(
+		t, contents,
+		"\texport GOMAXPROCS=4\n",
+		"\tgo build -v \\\n\t\t-p 4 \\\n",
+	)
+
+	contents = renderPkgbuild(t, pkgData{SourceDir: "foo", MainFile: "main.go"})
+	if strings.Contains(contents, "GOMAXPROCS") ||
+		strings.Contains(contents, "\t\t-p ") {
+		t.Fatalf("expected no build jobs in:\n%s", contents)
+	}
+}
+
+func TestPkgbuildRendersCustomProtocolSource(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		IsBinRelease: true,
+		DLAgents:     []string{"s3::/usr/bin/aws s3 cp %u %o"},
+		Sources: []pkgSource{
+			{URL: "s3://bucket/foo.tar.gz", Hash: "SKIP"},
+		},
+	})
+
+	assertContains(
+		t, contents,
+		"DLAGENTS=(\n"+
+			"\t\"s3::/usr/bin/aws s3 cp %u %o\"\n"+
+			"\t\"${DLAGENTS[@]}\"\n"+
+			")\n",
+		"source=(\n\t\"s3://bucket/foo.tar.gz\"\n)\n",
+	)
+}
+
+func TestPkgbuildRendersPkgrelVerbatim(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{PkgRel: "1.1"})
+	assertContains(t, contents, "\npkgrel=1.1\n")
+
+	contents = renderPkgbuild(t, pkgData{PkgRel: "1"})
+	assertContains(t, contents, "\npkgrel=${PKGREL:-1}\n")
+}
+
+func TestPkgbuildRendersUnlicensedLicenses(t *testing.T) {
+	for _, license := range []string{"none", "unknown"} {
+		contents := renderPkgbuild(t, pkgData{
+			Licenses: []string{getUnlicensedLicense([]string{license})},
+		})
+
+		assertContains(t, contents, "license=('"+license+"')\n")
+
+		if strings.Contains(contents, "usr/share/licenses") {
+			t.Fatalf("expected no license install in:\n%s", contents)
+		}
+	}
+}
+
+func TestPkgbuildRendersGoDependenciesAsComments(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		GoDependencies: []string{
+			"github.com/kovetskiy/lorg v1.2.0",
+			"github.com/reconquest/karma-go v1.3.1 (indirect)",
+		},
+	})
+
+	assertContains(
+		t, contents,
+		"# Go module dependencies:\n"+
+			"#   github.com/kovetskiy/lorg v1.2.0\n"+
+			"#   github.com/reconquest/karma-go v1.3.1 (indirect)\n"+
+			"\nbuild() {",
+	)
+
+	if strings.Count(contents, "kovetskiy/lorg") != 1 {
+		t.Fatalf("expected dependencies only in comments:\n%s", contents)
+	}
+}
+
+func TestPkgbuildListsMaintainersInOrder(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:    "foo",
+		Maintainer: "John Doe <john@example.com>",
+		CoMaintainers: getUniqueList([]string{
+			"Jane Roe <jane@example.com>",
+			"Jane Roe <jane@example.com>",
+		}),
+	})
+
+	assertContains(
+		t, contents,
+		"v"+version+"\n"+
+			"# Maintainer: John Doe <john@example.com>\n"+
+			"# Maintainer: Jane Roe <jane@example.com>\n"+
+			"pkgname=foo\n",
+	)
+}
+
+func TestPkgbuildBuildsWithMake(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:    "foo",
+		SourceDir:  "foo",
+		GoSrcDir:   "foo",
+		UseMake:    true,
+		MakeTarget: "release",
+		BinDir:     "usr/bin",
+	})
+
+	assertContains(
+		t, contents,
+		"makedepends=(\n\t'go'\n\t'git'\n\t'make'\n)\n",
+		"\techo \":: Building binary\"\n\tmake release\n}\n",
+		"package() {\n"+
+			"\tcd \"$srcdir/foo\"\n"+
+			"\tmake DESTDIR=\"$pkgdir\" install\n"+
+			"}\n",
+	)
+
+	for _, snippet := range []string{"go get", "go build", "go/bin/"} {
+		if strings.Contains(contents, snippet) {
+			t.Fatalf("expected no %q in:\n%s", snippet, contents)
+		}
+	}
+}
+
+func TestPkgbuildBuildsWithMakeDefaultTarget(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:   "foo",
+		SourceDir: "foo",
+		UseMake:   true,
+	})
+
+	assertContains(t, contents, "\tmake\n}\n")
+}
+
+func TestPkgbuildHintsImportOfValidPGPKeys(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName: "foo",
+		ValidPGPKeys: []string{
+			"0123456789ABCDEF0123456789ABCDEF01234567",
+		},
+	})
+
+	assertContains(
+		t, contents,
+		"# Keys should be imported before build, "+
+			"e.g. with 'gpg --recv-keys <KEY>'.\n"+
+			"validpgpkeys=(\n"+
+			"\t'0123456789ABCDEF0123456789ABCDEF01234567'\n"+
+			")\n",
+	)
+}
+
+func TestPkgbuildInstallsWithGoInstall(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:        "foo",
+		SourceDir:      "foo",
+		GoSrcDir:       "github.com/user/foo",
+		BinDir:         "usr/bin",
+		CmdDir:         "cmd/foo",
+		VersionVarName: "version",
+		IsGoInstall:    true,
+	})
+
+	assertContains(
+		t, contents,
+		"package() {\n"+
+			"\tcd \"$srcdir/go/src/github.com/user/foo/\"\n"+
+			"\n\texport GOPATH=\"$srcdir/go\"\n",
+		"\techo \":: Installing binary\"\n"+
+			"\tGOBIN=\"$pkgdir/usr/bin\" go install -v \\\n",
+		"\t\t-ldflags=\"-X main.version=$pkgver-$pkgrel\" \\\n"+
+			"\t\t./cmd/foo\n}\n",
+	)
+
+	for _, snippet := range []string{"go get", "go build", "go/bin/"} {
+		if strings.Contains(contents, snippet) {
+			t.Fatalf("expected no %q in:\n%s", snippet, contents)
+		}
+	}
+}
+
+func TestPkgbuildBuildsSingleMainFile(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:         "foo",
+		SourceDir:       "foo",
+		GoSrcDir:        "foo",
+		BinDir:          "usr/bin",
+		MainFile:        "tools/foo.go",
+		BuiltBinaryName: "foo",
+	})
+
+	assertContains(
+		t, contents,
+		"\techo \":: Building binary\"\n"+
+			"\tif [ ! -f go.mod ]; then\n"+
+			"\t\texport GO111MODULE=off\n"+
+			"\tfi\n"+
+			"\n"+
+			"\tgo build -v \\\n",
+		"\t\t-o \"$GOPATH/bin/foo\" \\\n"+
+			"\t\ttools/foo.go\n",
+	)
+
+	if strings.Contains(contents, "go get") {
+		t.Fatalf("expected no go get in:\n%s", contents)
+	}
+}
+
+func TestPkgbuildPinsShallowSourceToCommit(t *testing.T) {
+	tests := []struct {
+		ref      string
+		script   string
+		fragment string
+		commands int
+	}{
+		{"", `printf 'abc123\trefs/heads/master\n'`, "#commit=abc123\"", 1},
+		{
+			"branch=devel", `printf 'def456\trefs/heads/devel\n'`,
+			"#commit=def456\"", 1,
+		},
+		{"tag=v1.0.0", "exit 1", "#tag=v1.0.0\"", 0},
+		{"branch=devel", "exit 2", "#branch=devel\"", 1},
+	}
+
+	for _, test := range tests {
+		commands := fakeCommands(t, test.script)
+
+		refKind, refName, err := parseRef(test.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		refKind, refName = getPinnedRef(
+			"https://github.com/user/foo", refKind, refName, "master",
+		)
+
+		if len(*commands) != test.commands {
+			t.Errorf("%q: unexpected commands: %q", test.ref, *commands)
+		}
+
+		contents := renderPkgbuild(t, pkgData{
+			SourceDir:     "foo",
+			RepoURL:       "https://github.com/user/foo",
+			RefKind:       refKind,
+			RefName:       refName,
+			DefaultBranch: "master",
+			IsShallow:     true,
+		})
+
+		assertContains(
+			t, contents,
+			"# makepkg clones full history of git sources and can't make "+
+				"shallow clones",
+			"\"foo::git+https://github.com/user/foo"+test.fragment,
+		)
+	}
+}
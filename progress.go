@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	progressMinSize  = 16 * 1024 * 1024
+	progressInterval = 200 * time.Millisecond
+)
+
+var progressOutput = os.Stderr
+
+// progressWriter counts bytes written through it and periodically reports
+// progress in the same style as logSubStep. Progress is reported only on
+// terminal and is suppressed by --quiet.
+type progressWriter struct {
+	label   string
+	total   int64
+	written int64
+	output  io.Writer
+	last    time.Time
+}
+
+func newProgressWriter(label string, total int64) *progressWriter {
+	progress := &progressWriter{
+		label: label,
+		total: total,
+	}
+
+	if !isQuiet && isTerminal(progressOutput) {
+		progress.output = progressOutput
+	}
+
+	return progress
+}
+
+func (progress *progressWriter) Write(data []byte) (int, error) {
+	progress.written += int64(len(data))
+
+	if progress.output != nil && time.Since(progress.last) > progressInterval {
+		progress.last = time.Now()
+		progress.report()
+	}
+
+	return len(data), nil
+}
+
+// Done finishes progress line, if any was reported.
+func (progress *progressWriter) Done() {
+	if progress.output == nil || progress.last.IsZero() {
+		return
+	}
+
+	progress.report()
+	fmt.Fprintln(progress.output)
+}
+
+func (progress *progressWriter) report() {
+	total := "?"
+	if progress.total > 0 {
+		total = formatSize(progress.total)
+	}
+
+	fmt.Fprintf(
+		progress.output, "\r  %s%s: %s / %s\x1b[K",
+		colorize("1;34", "-> "), progress.label,
+		formatSize(progress.written), total,
+	)
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value, suffix := float64(size)/unit, "KiB"
+	for _, next := range []string{"MiB", "GiB"} {
+		if value < unit {
+			break
+		}
+
+		value, suffix = value/unit, next
+	}
+
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProgressWriterIsSuppressedWithoutTerminal(t *testing.T) {
+	output, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer output.Close()
+
+	defaultOutput := progressOutput
+	progressOutput = output
+	defer func() { progressOutput = defaultOutput }()
+
+	progress := newProgressWriter("Hashing foo", progressMinSize)
+
+	_, err = progress.Write([]byte(strings.Repeat("x", 1024)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress.Done()
+
+	contents, err := ioutil.ReadFile(output.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(contents) != 0 {
+		t.Fatalf("expected no progress output, got %q", contents)
+	}
+}
+
+func TestProgressWriterHonorsColorDecision(t *testing.T) {
+	defer func() { isColored = false }()
+
+	tests := []struct {
+		isColored bool
+		expected  string
+	}{
+		{false, "\r  -> Hashing foo: 1.0 KiB / 2.0 KiB\x1b[K"},
+		{
+			true,
+			"\r  \x1b[1;34m-> \x1b[39mHashing foo: 1.0 KiB / 2.0 KiB\x1b[K",
+		},
+	}
+
+	for _, test := range tests {
+		isColored = test.isColored
+
+		output := &strings.Builder{}
+		progress := &progressWriter{
+			label:   "Hashing foo",
+			total:   2048,
+			written: 1024,
+			output:  output,
+		}
+
+		progress.report()
+
+		if output.String() != test.expected {
+			t.Errorf(
+				"colored: %t: expected %q, got %q",
+				test.isColored, test.expected, output.String(),
+			)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected string
+	}{
+		{512, "512 B"},
+		{1536, "1.5 KiB"},
+		{16 * 1024 * 1024, "16.0 MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GiB"},
+	}
+
+	for _, test := range tests {
+		size := formatSize(test.size)
+		if size != test.expected {
+			t.Errorf("%d: expected %q, got %q", test.size, test.expected, size)
+		}
+	}
+}
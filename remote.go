@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	remoteSourceMaxSize = 512 * 1024 * 1024
+	remoteSourceTimeout = 5 * time.Minute
+)
+
+// resolveRemoteSums computes sums of downloadable sources, leaving 'SKIP' for
+// VCS and other sources which can't be downloaded directly or are explicitly
+// marked to skip sum. Downloaded files are kept in the output dir, where
+// makepkg will find them as well.
+func resolveRemoteSums(sources []pkgSource, outDir string) error {
+	for i := range sources {
+		source := &sources[i]
+
+		if source.SkipSum || !isDownloadableSource(source.URL) {
+			source.Hash = "SKIP"
+			continue
+		}
+
+		hash, err := getRemoteSourceHash(source.URL, outDir)
+		if err != nil {
+			return err
+		}
+
+		source.Hash = hash
+	}
+
+	return nil
+}
+
+func isDownloadableSource(source string) bool {
+	sourceURL, err := url.Parse(getSourceURL(source))
+	if err != nil {
+		return false
+	}
+
+	switch sourceURL.Scheme {
+	case "http", "https":
+		return true
+	default:
+		return false
+	}
+}
+
+// builtinSourceProtocols lists protocols which makepkg handles without
+// additional DLAGENTS entries, either by default download agents or by VCS
+// support.
+var builtinSourceProtocols = []string{
+	"file", "ftp", "http", "https", "rsync", "scp",
+	"bzr", "fossil", "git", "hg", "svn",
+}
+
+// getSourceProtocol returns protocol of source as makepkg sees it, like
+// 'git' for 'git+https://...', or empty string for local files.
+func getSourceProtocol(source string) string {
+	sourceURL := getSourceURL(source)
+
+	index := strings.Index(sourceURL, "://")
+	if index < 0 {
+		return ""
+	}
+
+	return strings.SplitN(sourceURL[:index], "+", 2)[0]
+}
+
+// parseDLAgents validates download agents specified in form
+// <PROTO>::<COMMAND>.
+func parseDLAgents(specs []string) ([]string, error) {
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "::", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" ||
+			strings.ContainsAny(spec, `"$`) {
+			return nil, fmt.Errorf(
+				"invalid download agent: %q, expected <PROTO>::<COMMAND>",
+				spec,
+			)
+		}
+	}
+
+	return specs, nil
+}
+
+func getRemoteSourceHash(source string, outDir string) (string, error) {
+	target := filepath.Join(outDir, getSourceFileName(source))
+
+	_, err := os.Stat(target)
+	if err == nil {
+		logSubStep("Using downloaded source: %s", target)
+		return getFileHash(target)
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	logSubStep("Downloading source: %s", getSourceURL(source))
+
+	err = downloadFile(getSourceURL(source), target)
+	if err != nil {
+		return "", err
+	}
+
+	return getFileHash(target)
+}
+
+func downloadFile(sourceURL string, target string) error {
+	client := http.Client{Timeout: getTimeout(remoteSourceTimeout)}
+
+	response, err := client.Get(sourceURL)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("can't download %s: %s", sourceURL, response.Status)
+	}
+
+	temp, err := os.Create(target + ".part")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	progress := newProgressWriter(
+		"Downloading "+filepath.Base(target), response.ContentLength,
+	)
+
+	size, err := io.Copy(
+		io.MultiWriter(temp, progress),
+		io.LimitReader(response.Body, remoteSourceMaxSize+1),
+	)
+
+	progress.Done()
+
+	if err != nil {
+		return err
+	}
+
+	if size > remoteSourceMaxSize {
+		return fmt.Errorf(
+			"can't download %s: file is larger than %d bytes",
+			sourceURL, remoteSourceMaxSize,
+		)
+	}
+
+	err = temp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), target)
+}
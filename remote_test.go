@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveRemoteSumsDownloadsSources(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			requests++
+
+			if request.URL.Path != "/foo-1.0.tar.gz" {
+				http.NotFound(writer, request)
+				return
+			}
+
+			fmt.Fprint(writer, "payload\n")
+		},
+	))
+	defer server.Close()
+
+	outDir := t.TempDir()
+
+	for run := 0; run < 2; run++ {
+		sources := []pkgSource{
+			{URL: server.URL + "/foo-1.0.tar.gz"},
+			{URL: "git+https://example.com/foo.git"},
+			{URL: "ftp://example.com/foo-1.0.tar.gz"},
+			{URL: server.URL + "/skipped.tar.gz", SkipSum: true},
+		}
+
+		err := resolveRemoteSums(sources, outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// md5 of payload served above
+		expected := []string{
+			"249c850f62ea50feb918b095fc56d763", "SKIP", "SKIP", "SKIP",
+		}
+		for i, source := range sources {
+			if source.Hash != expected[i] {
+				t.Fatalf(
+					"%s: expected %q, got %q",
+					source.URL, expected[i], source.Hash,
+				)
+			}
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("expected single download, got %d", requests)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(outDir, "foo-1.0.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "payload\n" {
+		t.Fatalf("unexpected downloaded contents: %q", contents)
+	}
+}
+
+func TestResolveRemoteSumsFailsOnMissingSource(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	err := resolveRemoteSums(
+		[]pkgSource{{URL: server.URL + "/missing.tar.gz"}}, t.TempDir(),
+	)
+	if err == nil {
+		t.Fatal("expected error for missing source")
+	}
+}
+
+func TestParseDLAgents(t *testing.T) {
+	agents, err := parseDLAgents([]string{
+		"s3::/usr/bin/aws s3 cp %u %o",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agents) != 1 || getSourceProtocol("s3://bucket/foo.tar.gz") != "s3" {
+		t.Fatalf("unexpected download agents: %q", agents)
+	}
+
+	for _, spec := range []string{"s3", "::cmd", "s3::", `s3::cmd "$x"`} {
+		_, err := parseDLAgents([]string{spec})
+		if err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
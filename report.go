@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type packageReport struct {
+	PkgName       string
+	VersionSource string
+	Files         int
+	Size          int64
+	Dependencies  []string
+	Service       bool
+	Gitignore     bool
+	InstallScript bool
+	Checksum      string
+}
+
+// getVersionSource describes where package version comes from.
+func getVersionSource(data pkgData) string {
+	switch {
+	case data.IsPkgVerPlaceholder:
+		return "placeholder " + data.PkgVer
+	case data.PkgVer != "":
+		return "static " + data.PkgVer
+	case data.RefKind == "tag":
+		return "git tag " + data.RefName
+	default:
+		return "git commit"
+	}
+}
+
+// getPackagedSize returns total size of packaged files placed into output
+// directory. Files which are not there, like remote sources, are not counted.
+func getPackagedSize(dir string, files []pkgFile) int64 {
+	size := int64(0)
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(dir, file.Name))
+		if err != nil {
+			continue
+		}
+
+		size += info.Size()
+	}
+
+	return size
+}
+
+// printReport writes short summary of generated package, so it can be
+// checked at a glance.
+func printReport(output io.Writer, report packageReport) error {
+	dependencies := strings.Join(report.Dependencies, ", ")
+	if dependencies == "" {
+		dependencies = "none"
+	}
+
+	_, err := fmt.Fprintf(
+		output,
+		"Package:        %s\n"+
+			"Version:        %s\n"+
+			"Files:          %d (%s)\n"+
+			"Depends:        %s\n"+
+			"Service:        %s\n"+
+			".gitignore:     %s\n"+
+			"Install script: %s\n"+
+			"Checksums:      %s\n",
+		report.PkgName,
+		report.VersionSource,
+		report.Files, formatSize(report.Size),
+		dependencies,
+		formatYesNo(report.Service),
+		formatYesNo(report.Gitignore),
+		formatYesNo(report.InstallScript),
+		report.Checksum,
+	)
+
+	return err
+}
+
+func formatYesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+
+	return "no"
+}
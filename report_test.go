@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintReportContainsPackageSummary(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "foo.conf"), "0123456789", 0644)
+	writeTestFile(t, filepath.Join(dir, "foo.service"), "01234", 0644)
+
+	files := []pkgFile{
+		{Name: "foo.conf", Path: "etc/foo/foo.conf"},
+		{Name: "foo.service", Path: "usr/lib/systemd/system/foo.service"},
+		{Name: "missing.conf", Path: "etc/foo/missing.conf"},
+	}
+
+	output := &strings.Builder{}
+	err := printReport(output, packageReport{
+		PkgName: "foo-git",
+		VersionSource: getVersionSource(pkgData{
+			RefKind: "tag", RefName: "v1.0.0",
+		}),
+		Files:         len(files),
+		Size:          getPackagedSize(dir, files),
+		Dependencies:  []string{"glibc", "sqlite"},
+		Service:       true,
+		InstallScript: true,
+		Checksum:      "md5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Package:        foo-git\n" +
+		"Version:        git tag v1.0.0\n" +
+		"Files:          3 (15 B)\n" +
+		"Depends:        glibc, sqlite\n" +
+		"Service:        yes\n" +
+		".gitignore:     no\n" +
+		"Install script: yes\n" +
+		"Checksums:      md5\n"
+	if output.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestGetVersionSource(t *testing.T) {
+	tests := []struct {
+		data     pkgData
+		expected string
+	}{
+		{pkgData{PkgVer: "1.0"}, "static 1.0"},
+		{pkgData{PkgVer: "1", IsPkgVerPlaceholder: true}, "placeholder 1"},
+		{pkgData{RefKind: "tag", RefName: "v2.0"}, "git tag v2.0"},
+		{pkgData{RefKind: "branch", RefName: "dev"}, "git commit"},
+	}
+
+	for _, test := range tests {
+		source := getVersionSource(test.data)
+		if source != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, source)
+		}
+	}
+}
@@ -7,9 +7,36 @@ var serviceTemplate = template.Must(
 Description={{.Description}}
 
 [Service]
-ExecStart=/usr/bin/{{.ExecName}}
+ExecStart=/{{.BinDir}}/{{.ExecName}}
+{{- if .OnCalendar}}
+Type=oneshot
+{{- else}}
 Restart=always
+{{- end}}
 
 [Install]
-WantedBy=multi-user.target
+WantedBy={{.WantedBy}}
+`))
+
+var socketTemplate = template.Must(
+	template.New("socket").Parse(`[Unit]
+Description={{.Description}} socket
+
+[Socket]
+ListenStream={{.ListenStream}}
+
+[Install]
+WantedBy=sockets.target
+`))
+
+var timerTemplate = template.Must(
+	template.New("timer").Parse(`[Unit]
+Description={{.Description}} timer
+
+[Timer]
+OnCalendar={{.OnCalendar}}
+Persistent=true
+
+[Install]
+WantedBy=timers.target
 `))
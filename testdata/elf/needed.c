@@ -0,0 +1,7 @@
+#include <math.h>
+#include <stdio.h>
+
+int main(int argc, char **argv) {
+	printf("%f\n", sqrt((double)argc));
+	return 0;
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const watchDebounceDelay = 500 * time.Millisecond
+
+// watchFiles watches specified files and directories and runs go-makepkg
+// with the same arguments, except --watch, on every change until interrupted.
+//
+// Files are watched through their parent directories, so editors which
+// replace file on save are handled as well. Any change in specified
+// directories, including output directory, triggers regeneration.
+func watchFiles(files []string, dirs []string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	defer watcher.Close()
+
+	watchedFiles := map[string]bool{}
+	watchedDirs := map[string]bool{}
+
+	for _, name := range files {
+		name, err = filepath.Abs(name)
+		if err != nil {
+			return err
+		}
+
+		watchedFiles[name] = true
+
+		err = addWatch(watcher, filepath.Dir(name), watchedDirs, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range dirs {
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+
+		err = filepath.Walk(
+			dir,
+			func(name string, info os.FileInfo, err error) error {
+				if err != nil || !info.IsDir() {
+					return err
+				}
+
+				return addWatch(watcher, name, watchedDirs, true)
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	changes := make(chan string)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if watchedFiles[event.Name] ||
+					watchedDirs[filepath.Dir(event.Name)] {
+					changes <- event.Name
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				logWarning("Watch error: %s", err)
+			}
+		}
+	}()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	logStep("Watching for changes, press Ctrl+C to stop...")
+
+	runWatchLoop(changes, interrupts, watchDebounceDelay, regenerate)
+
+	return nil
+}
+
+// addWatch adds directory to the watcher unless it's watched already.
+// Directories added as whole trigger regeneration on change of any file in
+// them, otherwise only changes of watched files are taken into account.
+func addWatch(
+	watcher *fsnotify.Watcher, dir string, dirs map[string]bool, whole bool,
+) error {
+	if _, ok := dirs[dir]; ok {
+		dirs[dir] = dirs[dir] || whole
+		return nil
+	}
+
+	dirs[dir] = whole
+
+	return watcher.Add(dir)
+}
+
+// runWatchLoop calls regenerate once changes stop arriving for specified
+// delay. Changes which arrive while regenerating, including ones caused by
+// regeneration itself, are discarded. Loop exits when stop is received.
+func runWatchLoop(
+	changes <-chan string, stop <-chan os.Signal,
+	delay time.Duration, regenerate func(),
+) {
+	for {
+		select {
+		case <-stop:
+			return
+
+		case name := <-changes:
+			logSubStep("Changed: %s", name)
+		}
+
+		timer := time.NewTimer(delay)
+
+	debounce:
+		for {
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+
+			case <-changes:
+				timer.Stop()
+				timer = time.NewTimer(delay)
+
+			case <-timer.C:
+				break debounce
+			}
+		}
+
+		regenerate()
+
+		settle := time.After(delay)
+
+	discard:
+		for {
+			select {
+			case <-stop:
+				return
+
+			case <-changes:
+
+			case <-settle:
+				break discard
+			}
+		}
+	}
+}
+
+// regenerate runs go-makepkg again with the same arguments, except --watch.
+// Errors are reported but do not stop watching.
+func regenerate() {
+	logStep("Regenerating...")
+
+	args := []string{}
+	for _, arg := range os.Args[1:] {
+		if arg != "--watch" {
+			args = append(args, arg)
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+
+	command := exec.Command(executable, args...)
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	err = command.Run()
+	if err != nil {
+		logWarning("Regeneration failed: %s", err)
+	}
+}
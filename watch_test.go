@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRunWatchLoopRegeneratesOnceForBurstOfChanges(t *testing.T) {
+	changes := make(chan string)
+	stop := make(chan os.Signal)
+	regenerated := make(chan struct{}, 10)
+
+	done := make(chan struct{})
+	go func() {
+		runWatchLoop(
+			changes, stop, 20*time.Millisecond,
+			func() { regenerated <- struct{}{} },
+		)
+
+		close(done)
+	}()
+
+	for _, name := range []string{"foo.conf", "foo.conf", "foo.service"} {
+		changes <- name
+	}
+
+	select {
+	case <-regenerated:
+	case <-time.After(time.Second):
+		t.Fatal("expected regeneration after change")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	close(stop)
+	<-done
+
+	if len(regenerated) != 0 {
+		t.Fatalf("expected single regeneration, got %d more", len(regenerated))
+	}
+}
+
+func TestRunWatchLoopStopsWithoutChanges(t *testing.T) {
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+
+	runWatchLoop(make(chan string), stop, time.Millisecond, func() {
+		t.Fatal("expected no regeneration")
+	})
+}
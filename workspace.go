@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goWorkFlags are set in GOFLAGS for workspace build. Workspace mode accepts
+// only readonly or vendor mode, and writable module cache lets makepkg clean
+// up build directory.
+const goWorkFlags = "-mod=readonly -modcacherw"
+
+// parseGoWorkUses returns module directories listed in 'use' directives of
+// go.work file contents, cleaned and relative to workspace root.
+func parseGoWorkUses(contents string) []string {
+	modules := []string{}
+
+	isUseBlock := false
+	for _, line := range strings.Split(contents, "\n") {
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case isUseBlock && fields[0] == ")":
+			isUseBlock = false
+			continue
+
+		case isUseBlock:
+
+		case fields[0] == "use" && len(fields) > 1 && fields[1] == "(":
+			isUseBlock = true
+			continue
+
+		case fields[0] == "use" && len(fields) > 1:
+			fields = fields[1:]
+
+		default:
+			continue
+		}
+
+		module := path.Clean(strings.Trim(fields[0], `"`+"`"))
+		if !isStringInList(module, modules) {
+			modules = append(modules, module)
+		}
+	}
+
+	sort.Strings(modules)
+
+	return modules
+}
+
+// getWorkspaceModules returns modules of go.work workspace located in the
+// root of local source checkout, or nil if checkout is not a workspace.
+func getWorkspaceModules(dir string) ([]string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "go.work"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return parseGoWorkUses(string(contents)), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetWorkspaceModulesParsesUseDirectives(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "go.work"), `go 1.18
+
+use ./tools // helper binaries
+use (
+	./cmd/server
+	"./lib"
+	./tools/
+)
+`, 0644)
+
+	modules, err := getWorkspaceModules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"cmd/server", "lib", "tools"}
+	if !reflect.DeepEqual(modules, expected) {
+		t.Fatalf("expected %q, got %q", expected, modules)
+	}
+
+	modules, err = getWorkspaceModules(t.TempDir())
+	if err != nil || modules != nil {
+		t.Fatalf("expected no workspace, got %q, error: %v", modules, err)
+	}
+}
+
+func TestPkgbuildBuildsSelectedWorkspaceModule(t *testing.T) {
+	contents := renderPkgbuild(t, pkgData{
+		PkgName:         "foo",
+		SourceDir:       "foo",
+		GoSrcDir:        "foo",
+		BinDir:          "usr/bin",
+		WorkspaceModule: "cmd/server",
+		GoFlags:         goWorkFlags,
+		BuiltBinaryName: "server",
+	})
+
+	assertContains(
+		t, contents,
+		"makedepends=(\n\t'go'\n\t'git'\n)\n",
+		"\techo \":: Building binary\"\n"+
+			"\tcd \"cmd/server\"\n"+
+			"\texport GOWORK=\"$srcdir/go/src/foo/go.work\"\n"+
+			"\texport GOFLAGS=\"-mod=readonly -modcacherw\"\n"+
+			"\n"+
+			"\tgo build -v \\\n",
+		"\t\t-o \"$GOPATH/bin/server\" \\\n\t\t./\n",
+	)
+}